@@ -0,0 +1,149 @@
+package bn256
+
+// This file implements fixed-base multiplication by the generators of G₁, G₂
+// and GT with precomputed tables. The scalar is split into 4-bit windows and
+// table entry (i, d) holds the generator multiplied by d·2^(4i), so a base
+// multiplication is one table addition per window and no doublings.
+
+import (
+	"crypto/subtle"
+	"sync"
+)
+
+const (
+	// baseWindow is the width, in bits, of the windows of the scalar.
+	baseWindow = 4
+	// baseWindows is the number of windows in a 256-bit scalar.
+	baseWindows = 256 / baseWindow
+	// baseEntries is the number of non-zero values of a window.
+	baseEntries = 1<<baseWindow - 1
+)
+
+var (
+	curveGenTableOnce sync.Once
+	// curveGenTable[i*baseEntries+d-1] is curveGen*(d·2^(4i)), in affine
+	// form.
+	curveGenTable []curvePoint
+
+	twistGenTableOnce sync.Once
+	// twistGenTable[i*baseEntries+d-1] is twistGen*(d·2^(4i)), in affine
+	// form.
+	twistGenTable []twistPoint
+
+	gfP12GenTableOnce sync.Once
+	// gfP12GenTable[i*baseEntries+d-1] is gfP12Gen^(d·2^(4i)).
+	gfP12GenTable []gfP12
+)
+
+func buildCurveGenTable() {
+	table := make([]curvePoint, baseWindows*baseEntries)
+
+	base := &curvePoint{}
+	base.Set(curveGen)
+	for i := 0; i < baseWindows; i++ {
+		row := table[i*baseEntries : (i+1)*baseEntries]
+		row[0].Set(base)
+		for d := 1; d < baseEntries; d++ {
+			row[d].Add(&row[d-1], base)
+		}
+		base.Double(&row[baseEntries/2])
+	}
+	curvePointsMakeAffine(table)
+
+	curveGenTable = table
+}
+
+func buildTwistGenTable() {
+	table := make([]twistPoint, baseWindows*baseEntries)
+
+	base := &twistPoint{}
+	base.Set(twistGen)
+	for i := 0; i < baseWindows; i++ {
+		row := table[i*baseEntries : (i+1)*baseEntries]
+		row[0].Set(base)
+		for d := 1; d < baseEntries; d++ {
+			row[d].Add(&row[d-1], base)
+		}
+		base.Double(&row[baseEntries/2])
+	}
+	twistPointsMakeAffine(table)
+
+	twistGenTable = table
+}
+
+func buildGFp12GenTable() {
+	table := make([]gfP12, baseWindows*baseEntries)
+
+	base := (&gfP12{}).Set(gfP12Gen)
+	for i := 0; i < baseWindows; i++ {
+		row := table[i*baseEntries : (i+1)*baseEntries]
+		row[0].Set(base)
+		for d := 1; d < baseEntries; d++ {
+			row[d].Mul(&row[d-1], base)
+		}
+		base.CyclotomicSquare(&row[baseEntries/2])
+	}
+
+	gfP12GenTable = table
+}
+
+// MulBase sets c to curveGen*k, where k is a scalar in little-endian 64-bit
+// words, in constant time with respect to k.
+func (c *curvePoint) MulBase(k *[4]uint64) {
+	curveGenTableOnce.Do(buildCurveGenTable)
+
+	sum, t := &curvePoint{}, &curvePoint{}
+	sum.SetInfinity()
+	for i := 0; i < baseWindows; i++ {
+		// The table holds affine points, which are also valid in projective
+		// coordinates, and the point at infinity is (0:1:0) in both.
+		d := scalarWindow(k, uint(i)*baseWindow, baseWindow)
+		t.SetInfinity()
+		row := curveGenTable[i*baseEntries : (i+1)*baseEntries]
+		for j := range row {
+			t.Cmov(&row[j], subtle.ConstantTimeEq(int32(j+1), int32(d)))
+		}
+		sum.AddProjective(sum, t)
+	}
+
+	c.ProjectiveToJacobian(sum)
+}
+
+// MulBase sets c to twistGen*k, where k is a scalar in little-endian 64-bit
+// words, in constant time with respect to k.
+func (c *twistPoint) MulBase(k *[4]uint64) {
+	twistGenTableOnce.Do(buildTwistGenTable)
+
+	sum, t := &twistPoint{}, &twistPoint{}
+	sum.SetInfinity()
+	for i := 0; i < baseWindows; i++ {
+		d := scalarWindow(k, uint(i)*baseWindow, baseWindow)
+		t.SetInfinity()
+		row := twistGenTable[i*baseEntries : (i+1)*baseEntries]
+		for j := range row {
+			t.Cmov(&row[j], subtle.ConstantTimeEq(int32(j+1), int32(d)))
+		}
+		sum.AddProjective(sum, t)
+	}
+
+	c.ProjectiveToJacobian(sum)
+}
+
+// ExpBase sets e to gfP12Gen^k, where k is a scalar in little-endian 64-bit
+// words, in constant time with respect to k, and then returns e.
+func (e *gfP12) ExpBase(k *[4]uint64) *gfP12 {
+	gfP12GenTableOnce.Do(buildGFp12GenTable)
+
+	sum, t := (&gfP12{}).SetOne(), &gfP12{}
+	for i := 0; i < baseWindows; i++ {
+		d := scalarWindow(k, uint(i)*baseWindow, baseWindow)
+		t.SetOne()
+		row := gfP12GenTable[i*baseEntries : (i+1)*baseEntries]
+		for j := range row {
+			t.Cmov(&row[j], subtle.ConstantTimeEq(int32(j+1), int32(d)))
+		}
+		sum.Mul(sum, t)
+	}
+
+	return e.Set(sum)
+}
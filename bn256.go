@@ -18,7 +18,7 @@ package bn256
 
 import (
 	"crypto/rand"
-	"errors"
+	"crypto/subtle"
 	"io"
 	"math/big"
 )
@@ -30,16 +30,25 @@ func randomK(r io.Reader) (k *big.Int, err error) {
 			return
 		}
 	}
-
-	return
 }
 
-// G1 is an abstract cyclic group. The zero value is suitable for use as the
-// output of an operation, but cannot be used as an input.
+// G1 is an abstract cyclic group. The zero value is the identity element, and
+// can be used both as an input and as the output of an operation.
 type G1 struct {
 	p *curvePoint
 }
 
+// point returns the point that e holds, which is the point at infinity for the
+// zero value of G1.
+func (e *G1) point() *curvePoint {
+	if e.p == nil {
+		c := &curvePoint{}
+		c.SetInfinity()
+		return c
+	}
+	return e.p
+}
+
 // RandomG1 returns x and g₁ˣ where x is a random, non-zero number read from r.
 func RandomG1(r io.Reader) (*big.Int, *G1, error) {
 	k, err := randomK(r)
@@ -50,17 +59,23 @@ func RandomG1(r io.Reader) (*big.Int, *G1, error) {
 	return k, new(G1).ScalarBaseMult(k), nil
 }
 
+// GeneratorG1 returns the generator of G₁.
+func GeneratorG1() *G1 {
+	return new(G1).SetGenerator()
+}
+
 func (g *G1) String() string {
-	return "bn256.G1" + g.p.String()
+	return "bn256.G1" + g.point().String()
 }
 
 // ScalarBaseMult sets e to g*k where g is the generator of the group and then
-// returns e.
+// returns e. Its timing does not depend on k, so k may be a private key.
 func (e *G1) ScalarBaseMult(k *big.Int) *G1 {
 	if e.p == nil {
 		e.p = &curvePoint{}
 	}
-	e.p.Mul(curveGen, k)
+	w := scalarWords(k)
+	e.p.MulBase(&w)
 	return e
 }
 
@@ -69,7 +84,60 @@ func (e *G1) ScalarMult(a *G1, k *big.Int) *G1 {
 	if e.p == nil {
 		e.p = &curvePoint{}
 	}
-	e.p.Mul(a.p, k)
+	e.p.Mul(a.point(), k)
+	return e
+}
+
+// ScalarMultCT sets e to a*k and then returns e. Unlike ScalarMult, its timing
+// does not depend on k, so k may be a private key.
+func (e *G1) ScalarMultCT(a *G1, k *big.Int) *G1 {
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	w := scalarWords(k)
+	e.p.MulCT(a.point(), &w)
+	return e
+}
+
+// ScalarBaseMultScalar sets e to g*k where g is the generator of the group and
+// then returns e. Its timing does not depend on k.
+func (e *G1) ScalarBaseMultScalar(k *Scalar) *G1 {
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	w := k.words()
+	e.p.MulBase(&w)
+	return e
+}
+
+// ScalarMultScalar sets e to a*k and then returns e. Its timing does not depend
+// on k.
+func (e *G1) ScalarMultScalar(a *G1, k *Scalar) *G1 {
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	w := k.words()
+	e.p.MulCT(a.point(), &w)
+	return e
+}
+
+// MultiScalarMult sets e to the sum of points[i]*scalars[i] and then returns
+// e. For more than a handful of points this is much faster than calling
+// ScalarMult and Add for each of them. It panics if points and scalars have
+// different lengths.
+func (e *G1) MultiScalarMult(points []*G1, scalars []*big.Int) *G1 {
+	if len(points) != len(scalars) {
+		panic("bn256: mismatched number of points and scalars")
+	}
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+
+	ps := make([]*curvePoint, len(points))
+	for i := range points {
+		ps[i] = points[i].point()
+	}
+	e.p.MultiMul(ps, scalars)
 	return e
 }
 
@@ -78,7 +146,7 @@ func (e *G1) Add(a, b *G1) *G1 {
 	if e.p == nil {
 		e.p = &curvePoint{}
 	}
-	e.p.Add(a.p, b.p)
+	e.p.Add(a.point(), b.point())
 	return e
 }
 
@@ -87,7 +155,27 @@ func (e *G1) Neg(a *G1) *G1 {
 	if e.p == nil {
 		e.p = &curvePoint{}
 	}
-	e.p.Neg(a.p)
+	e.p.Neg(a.point())
+	return e
+}
+
+// Sub sets e to a-b and then returns e.
+func (e *G1) Sub(a, b *G1) *G1 {
+	negB := &curvePoint{}
+	negB.Neg(b.point())
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	e.p.Add(a.point(), negB)
+	return e
+}
+
+// Double sets e to 2a and then returns e.
+func (e *G1) Double(a *G1) *G1 {
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	e.p.Double(a.point())
 	return e
 }
 
@@ -96,32 +184,71 @@ func (e *G1) Set(a *G1) *G1 {
 	if e.p == nil {
 		e.p = &curvePoint{}
 	}
-	e.p.Set(a.p)
+	e.p.Set(a.point())
+	return e
+}
+
+// Equal returns true iff e and a are the same element of the group. It
+// compares projective coordinates, without converting either element to
+// affine form, and modifies neither.
+func (e *G1) Equal(a *G1) bool {
+	return e.point().Equal(a.point())
+}
+
+// IsIdentity returns true iff e is the identity element of the group.
+func (e *G1) IsIdentity() bool {
+	return e.point().IsInfinity()
+}
+
+// SetIdentity sets e to the identity element of the group and then returns e.
+func (e *G1) SetIdentity() *G1 {
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	e.p.SetInfinity()
+	return e
+}
+
+// SetGenerator sets e to the generator of the group, g₁, and then returns e.
+func (e *G1) SetGenerator() *G1 {
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	e.p.Set(curveGen)
 	return e
 }
 
-// Marshal converts e to a byte slice.
+// Marshal converts e to a byte slice. It doesn't modify e, so it is safe to
+// call concurrently with other methods that only read e.
 func (e *G1) Marshal() []byte {
+	return e.appendMarshal(nil)
+}
+
+// appendMarshal appends the output of Marshal to b and returns the result.
+func (e *G1) appendMarshal(b []byte) []byte {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
 
+	n := len(b)
+	b = append(b, make([]byte, numBytes*2)...)
+	ret := b[n:]
 	if e.p == nil {
-		e.p = &curvePoint{}
+		return b
 	}
-
-	e.p.MakeAffine()
-	ret := make([]byte, numBytes*2)
-	if e.p.IsInfinity() {
-		return ret
+	a := &curvePoint{}
+	a.Set(e.p)
+	a.MakeAffine()
+	if a.IsInfinity() {
+		return b
 	}
 	temp := &gfP{}
 
-	montDecode(temp, &e.p.x)
+	montDecode(temp, &a.x)
 	temp.Marshal(ret)
-	montDecode(temp, &e.p.y)
+	montDecode(temp, &a.y)
 	temp.Marshal(ret[numBytes:])
 
-	return ret
+	return b
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
@@ -131,44 +258,148 @@ func (e *G1) Unmarshal(m []byte) ([]byte, error) {
 	const numBytes = 256 / 8
 
 	if len(m) < 2*numBytes {
-		return nil, errors.New("bn256: not enough data")
+		return nil, newDecodeError("G1", len(m), ErrShortBuffer)
 	}
 
-	if e.p == nil {
-		e.p = &curvePoint{}
-	} else {
-		e.p.x, e.p.y = gfP{0}, gfP{0}
+	// Decode into c, so that e is unchanged if m is invalid.
+	c := &curvePoint{}
+	if err := c.x.Unmarshal(m); err != nil {
+		return nil, newDecodeError("G1", 0, err)
+	} else if err := c.y.Unmarshal(m[numBytes:]); err != nil {
+		return nil, newDecodeError("G1", numBytes, err)
 	}
-
-	e.p.x.Unmarshal(m)
-	e.p.y.Unmarshal(m[numBytes:])
-	montEncode(&e.p.x, &e.p.x)
-	montEncode(&e.p.y, &e.p.y)
+	montEncode(&c.x, &c.x)
+	montEncode(&c.y, &c.y)
 
 	zero := gfP{0}
-	if e.p.x == zero && e.p.y == zero {
+	if c.x == zero && c.y == zero {
 		// This is the point at infinity.
-		e.p.y = *newGFp(1)
-		e.p.z = gfP{0}
-		e.p.t = gfP{0}
+		c.y = *newGFp(1)
+		c.z = gfP{0}
+		c.t = gfP{0}
 	} else {
-		e.p.z = *newGFp(1)
-		e.p.t = *newGFp(1)
+		c.z = *newGFp(1)
+		c.t = *newGFp(1)
 
-		if !e.p.IsOnCurve() {
-			return nil, errors.New("bn256: malformed point")
+		if !c.IsOnCurve() {
+			return nil, newDecodeError("G1", 0, ErrNotOnCurve)
 		}
 	}
 
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	e.p.Set(c)
 	return m[2*numBytes:], nil
 }
 
-// G2 is an abstract cyclic group. The zero value is suitable for use as the
-// output of an operation, but cannot be used as an input.
+// MarshalCompressed converts e to a byte slice that holds only the
+// x-coordinate of e and the sign of its y-coordinate.
+func (e *G1) MarshalCompressed() []byte {
+	// Each value is a 256-bit number.
+	const numBytes = 256 / 8
+
+	ret := make([]byte, 1+numBytes)
+	if e.p == nil {
+		return ret
+	}
+	a := &curvePoint{}
+	a.Set(e.p)
+	a.MakeAffine()
+	if a.IsInfinity() {
+		return ret
+	}
+
+	ret[0] = 0x02
+	if sign0(&a.y) == 1 {
+		ret[0] = 0x03
+	}
+	temp := &gfP{}
+	montDecode(temp, &a.x)
+	temp.Marshal(ret[1:])
+
+	return ret
+}
+
+// UnmarshalCompressed sets e to the result of converting the output of
+// MarshalCompressed back into a group element and then returns e.
+func (e *G1) UnmarshalCompressed(m []byte) ([]byte, error) {
+	// Each value is a 256-bit number.
+	const numBytes = 256 / 8
+
+	if len(m) < 1+numBytes {
+		return nil, newDecodeError("G1", len(m), ErrShortBuffer)
+	}
+
+	if m[0] == 0x00 {
+		for i, b := range m[1 : 1+numBytes] {
+			if b != 0 {
+				return nil, newDecodeError("G1", 1+i, ErrNonCanonical)
+			}
+		}
+		if e.p == nil {
+			e.p = &curvePoint{}
+		}
+		e.p.SetInfinity()
+		return m[1+numBytes:], nil
+	} else if m[0] != 0x02 && m[0] != 0x03 {
+		return nil, newDecodeError("G1", 0, ErrInvalidFlag)
+	}
+
+	x := &gfP{}
+	if err := x.Unmarshal(m[1:]); err != nil {
+		return nil, newDecodeError("G1", 1, err)
+	}
+	montEncode(x, x)
+
+	y, y2 := &gfP{}, &gfP{}
+	gfpMul(y2, x, x)
+	gfpMul(y2, y2, x)
+	gfpAdd(y2, y2, curveB)
+	y.Sqrt(y2)
+
+	t := &gfP{}
+	gfpMul(t, y, y)
+	if *t != *y2 {
+		return nil, newDecodeError("G1", 1, ErrNotOnCurve)
+	}
+
+	sign := 2*int(m[0]&1) - 1
+	if sign0(y) != sign {
+		gfpNeg(y, y)
+		if sign0(y) != sign {
+			return nil, newDecodeError("G1", 0, ErrNonCanonical)
+		}
+	}
+
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+	e.p.x.Set(x)
+	e.p.y.Set(y)
+	e.p.z = *newGFp(1)
+	e.p.t = *newGFp(1)
+
+	return m[1+numBytes:], nil
+}
+
+// G2 is an abstract cyclic group. The zero value is the identity element, and
+// can be used both as an input and as the output of an operation.
 type G2 struct {
 	p *twistPoint
 }
 
+// point returns the point that e holds, which is the point at infinity for the
+// zero value of G2.
+func (e *G2) point() *twistPoint {
+	if e.p == nil {
+		c := &twistPoint{}
+		c.SetInfinity()
+		return c
+	}
+	return e.p
+}
+
 // RandomG2 returns x and g₂ˣ where x is a random, non-zero number read from r.
 func RandomG2(r io.Reader) (*big.Int, *G2, error) {
 	k, err := randomK(r)
@@ -179,17 +410,23 @@ func RandomG2(r io.Reader) (*big.Int, *G2, error) {
 	return k, new(G2).ScalarBaseMult(k), nil
 }
 
+// GeneratorG2 returns the generator of G₂.
+func GeneratorG2() *G2 {
+	return new(G2).SetGenerator()
+}
+
 func (e *G2) String() string {
-	return "bn256.G2" + e.p.String()
+	return "bn256.G2" + e.point().String()
 }
 
 // ScalarBaseMult sets e to g*k where g is the generator of the group and then
-// returns out.
+// returns out. Its timing does not depend on k, so k may be a private key.
 func (e *G2) ScalarBaseMult(k *big.Int) *G2 {
 	if e.p == nil {
 		e.p = &twistPoint{}
 	}
-	e.p.Mul(twistGen, k)
+	w := scalarWords(k)
+	e.p.MulBase(&w)
 	return e
 }
 
@@ -198,7 +435,60 @@ func (e *G2) ScalarMult(a *G2, k *big.Int) *G2 {
 	if e.p == nil {
 		e.p = &twistPoint{}
 	}
-	e.p.Mul(a.p, k)
+	e.p.Mul(a.point(), k)
+	return e
+}
+
+// ScalarMultCT sets e to a*k and then returns e. Unlike ScalarMult, its timing
+// does not depend on k, so k may be a private key.
+func (e *G2) ScalarMultCT(a *G2, k *big.Int) *G2 {
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	w := scalarWords(k)
+	e.p.MulCT(a.point(), &w)
+	return e
+}
+
+// ScalarBaseMultScalar sets e to g*k where g is the generator of the group and
+// then returns e. Its timing does not depend on k.
+func (e *G2) ScalarBaseMultScalar(k *Scalar) *G2 {
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	w := k.words()
+	e.p.MulBase(&w)
+	return e
+}
+
+// ScalarMultScalar sets e to a*k and then returns e. Its timing does not depend
+// on k.
+func (e *G2) ScalarMultScalar(a *G2, k *Scalar) *G2 {
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	w := k.words()
+	e.p.MulCT(a.point(), &w)
+	return e
+}
+
+// MultiScalarMult sets e to the sum of points[i]*scalars[i] and then returns
+// e. For more than a handful of points this is much faster than calling
+// ScalarMult and Add for each of them. It panics if points and scalars have
+// different lengths.
+func (e *G2) MultiScalarMult(points []*G2, scalars []*big.Int) *G2 {
+	if len(points) != len(scalars) {
+		panic("bn256: mismatched number of points and scalars")
+	}
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+
+	ps := make([]*twistPoint, len(points))
+	for i := range points {
+		ps[i] = points[i].point()
+	}
+	e.p.MultiMul(ps, scalars)
 	return e
 }
 
@@ -207,7 +497,7 @@ func (e *G2) Add(a, b *G2) *G2 {
 	if e.p == nil {
 		e.p = &twistPoint{}
 	}
-	e.p.Add(a.p, b.p)
+	e.p.Add(a.point(), b.point())
 	return e
 }
 
@@ -216,7 +506,27 @@ func (e *G2) Neg(a *G2) *G2 {
 	if e.p == nil {
 		e.p = &twistPoint{}
 	}
-	e.p.Neg(a.p)
+	e.p.Neg(a.point())
+	return e
+}
+
+// Sub sets e to a-b and then returns e.
+func (e *G2) Sub(a, b *G2) *G2 {
+	negB := &twistPoint{}
+	negB.Neg(b.point())
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	e.p.Add(a.point(), negB)
+	return e
+}
+
+// Double sets e to 2a and then returns e.
+func (e *G2) Double(a *G2) *G2 {
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	e.p.Double(a.point())
 	return e
 }
 
@@ -225,38 +535,77 @@ func (e *G2) Set(a *G2) *G2 {
 	if e.p == nil {
 		e.p = &twistPoint{}
 	}
-	e.p.Set(a.p)
+	e.p.Set(a.point())
 	return e
 }
 
-// Marshal converts e into a byte slice.
+// Equal returns true iff e and a are the same element of the group. It
+// compares projective coordinates, without converting either element to
+// affine form, and modifies neither.
+func (e *G2) Equal(a *G2) bool {
+	return e.point().Equal(a.point())
+}
+
+// IsIdentity returns true iff e is the identity element of the group.
+func (e *G2) IsIdentity() bool {
+	return e.point().IsInfinity()
+}
+
+// SetIdentity sets e to the identity element of the group and then returns e.
+func (e *G2) SetIdentity() *G2 {
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	e.p.SetInfinity()
+	return e
+}
+
+// SetGenerator sets e to the generator of the group, g₂, and then returns e.
+func (e *G2) SetGenerator() *G2 {
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	e.p.Set(twistGen)
+	return e
+}
+
+// Marshal converts e into a byte slice. It doesn't modify e, so it is safe to
+// call concurrently with other methods that only read e.
 func (e *G2) Marshal() []byte {
+	return e.appendMarshal(nil)
+}
+
+// appendMarshal appends the output of Marshal to b and returns the result.
+func (e *G2) appendMarshal(b []byte) []byte {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
 
 	if e.p == nil {
-		e.p = &twistPoint{}
+		return append(b, 0x00)
 	}
-
-	e.p.MakeAffine()
-	if e.p.IsInfinity() {
-		return make([]byte, 1)
+	a := &twistPoint{}
+	a.Set(e.p)
+	a.MakeAffine()
+	if a.IsInfinity() {
+		return append(b, 0x00)
 	}
 
-	ret := make([]byte, 1+numBytes*4)
+	n := len(b)
+	b = append(b, make([]byte, 1+numBytes*4)...)
+	ret := b[n:]
 	ret[0] = 0x01
 	temp := &gfP{}
 
-	montDecode(temp, &e.p.x.x)
+	montDecode(temp, &a.x.x)
 	temp.Marshal(ret[1:])
-	montDecode(temp, &e.p.x.y)
+	montDecode(temp, &a.x.y)
 	temp.Marshal(ret[1+numBytes:])
-	montDecode(temp, &e.p.y.x)
+	montDecode(temp, &a.y.x)
 	temp.Marshal(ret[1+2*numBytes:])
-	montDecode(temp, &e.p.y.y)
+	montDecode(temp, &a.y.y)
 	temp.Marshal(ret[1+3*numBytes:])
 
-	return ret
+	return b
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
@@ -265,49 +614,173 @@ func (e *G2) Unmarshal(m []byte) ([]byte, error) {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
 
-	if e.p == nil {
-		e.p = &twistPoint{}
-	}
-
 	if len(m) > 0 && m[0] == 0x00 {
+		if e.p == nil {
+			e.p = &twistPoint{}
+		}
 		e.p.SetInfinity()
 		return m[1:], nil
 	} else if len(m) > 0 && m[0] != 0x01 {
-		return nil, errors.New("bn256: malformed point")
+		return nil, newDecodeError("G2", 0, ErrInvalidFlag)
 	} else if len(m) < 1+4*numBytes {
-		return nil, errors.New("bn256: not enough data")
+		return nil, newDecodeError("G2", len(m), ErrShortBuffer)
 	}
 
-	e.p.x.x.Unmarshal(m[1:])
-	e.p.x.y.Unmarshal(m[1+numBytes:])
-	e.p.y.x.Unmarshal(m[1+2*numBytes:])
-	e.p.y.y.Unmarshal(m[1+3*numBytes:])
-	montEncode(&e.p.x.x, &e.p.x.x)
-	montEncode(&e.p.x.y, &e.p.x.y)
-	montEncode(&e.p.y.x, &e.p.y.x)
-	montEncode(&e.p.y.y, &e.p.y.y)
+	// Decode into c, so that e is unchanged if m is invalid.
+	c := &twistPoint{}
+	if err := c.x.x.Unmarshal(m[1:]); err != nil {
+		return nil, newDecodeError("G2", 1, err)
+	} else if err := c.x.y.Unmarshal(m[1+numBytes:]); err != nil {
+		return nil, newDecodeError("G2", 1+numBytes, err)
+	} else if err := c.y.x.Unmarshal(m[1+2*numBytes:]); err != nil {
+		return nil, newDecodeError("G2", 1+2*numBytes, err)
+	} else if err := c.y.y.Unmarshal(m[1+3*numBytes:]); err != nil {
+		return nil, newDecodeError("G2", 1+3*numBytes, err)
+	}
+	montEncode(&c.x.x, &c.x.x)
+	montEncode(&c.x.y, &c.x.y)
+	montEncode(&c.y.x, &c.y.x)
+	montEncode(&c.y.y, &c.y.y)
+
+	// The point at infinity is only encoded as the single byte 0x00.
+	if c.x.IsZero() && c.y.IsZero() {
+		return nil, newDecodeError("G2", 0, ErrNonCanonical)
+	}
+	c.z.SetOne()
+	c.t.SetOne()
 
-	if e.p.x.IsZero() && e.p.y.IsZero() {
-		// This is the point at infinity.
-		e.p.y.SetOne()
-		e.p.z.SetZero()
-		e.p.t.SetZero()
-	} else {
-		e.p.z.SetOne()
-		e.p.t.SetOne()
+	if !c.IsOnCurve() {
+		return nil, newDecodeError("G2", 1, ErrNotOnCurve)
+	} else if !c.IsInSubgroup() {
+		return nil, newDecodeError("G2", 1, ErrNotInSubgroup)
+	}
+
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	e.p.Set(c)
+	return m[1+4*numBytes:], nil
+}
+
+// IsInSubgroup returns true iff e is in the subgroup of order Order. Points
+// decoded by Unmarshal and UnmarshalCompressed are always in the subgroup.
+func (e *G2) IsInSubgroup() bool {
+	if e.p == nil {
+		return true
+	}
+	return e.p.IsInSubgroup()
+}
+
+// MarshalCompressed converts e to a byte slice that holds only the
+// x-coordinate of e and the sign of its y-coordinate.
+func (e *G2) MarshalCompressed() []byte {
+	// Each value is a 256-bit number.
+	const numBytes = 256 / 8
+
+	ret := make([]byte, 1+2*numBytes)
+	if e.p == nil {
+		return ret
+	}
+	a := &twistPoint{}
+	a.Set(e.p)
+	a.MakeAffine()
+	if a.IsInfinity() {
+		return ret
+	}
+
+	ret[0] = 0x02
+	if sign0GFp2(&a.y) == 1 {
+		ret[0] = 0x03
+	}
+	temp := &gfP{}
+	montDecode(temp, &a.x.x)
+	temp.Marshal(ret[1:])
+	montDecode(temp, &a.x.y)
+	temp.Marshal(ret[1+numBytes:])
 
-		if !e.p.IsOnCurve() {
-			return nil, errors.New("bn256: malformed point")
+	return ret
+}
+
+// UnmarshalCompressed sets e to the result of converting the output of
+// MarshalCompressed back into a group element and then returns e.
+func (e *G2) UnmarshalCompressed(m []byte) ([]byte, error) {
+	// Each value is a 256-bit number.
+	const numBytes = 256 / 8
+
+	if len(m) < 1+2*numBytes {
+		return nil, newDecodeError("G2", len(m), ErrShortBuffer)
+	}
+
+	if m[0] == 0x00 {
+		for i, b := range m[1 : 1+2*numBytes] {
+			if b != 0 {
+				return nil, newDecodeError("G2", 1+i, ErrNonCanonical)
+			}
+		}
+		if e.p == nil {
+			e.p = &twistPoint{}
 		}
+		e.p.SetInfinity()
+		return m[1+2*numBytes:], nil
+	} else if m[0] != 0x02 && m[0] != 0x03 {
+		return nil, newDecodeError("G2", 0, ErrInvalidFlag)
 	}
 
-	return m[1+4*numBytes:], nil
+	x := &gfP2{}
+	if err := x.x.Unmarshal(m[1:]); err != nil {
+		return nil, newDecodeError("G2", 1, err)
+	} else if err := x.y.Unmarshal(m[1+numBytes:]); err != nil {
+		return nil, newDecodeError("G2", 1+numBytes, err)
+	}
+	montEncode(&x.x, &x.x)
+	montEncode(&x.y, &x.y)
+
+	y2 := (&gfP2{}).Square(x)
+	y2.Mul(y2, x).Add(y2, twistB)
+	y := (&gfP2{}).Sqrt(y2)
+
+	if t := (&gfP2{}).Square(y); *t != *y2 {
+		return nil, newDecodeError("G2", 1, ErrNotOnCurve)
+	}
+
+	sign := 2*int(m[0]&1) - 1
+	if sign0GFp2(y) != sign {
+		y.Neg(y)
+		if sign0GFp2(y) != sign {
+			return nil, newDecodeError("G2", 0, ErrNonCanonical)
+		}
+	}
+
+	c := &twistPoint{x: *x, y: *y}
+	c.z.SetOne()
+	c.t.SetOne()
+	if !c.IsInSubgroup() {
+		return nil, newDecodeError("G2", 1, ErrNotInSubgroup)
+	}
+
+	if e.p == nil {
+		e.p = &twistPoint{}
+	}
+	e.p.Set(c)
+	return m[1+2*numBytes:], nil
 }
 
-// GT is an abstract cyclic group. The zero value is suitable for use as the
-// output of an operation, but cannot be used as an input.
+// GT is an abstract cyclic group. The zero value is the identity element, and
+// can be used both as an input and as the output of an operation.
 type GT struct {
 	p *gfP12
+	// unfinalized is true if p derives from the output of Miller, so it may
+	// not be in GT.
+	unfinalized bool
+}
+
+// element returns the element of GF(p¹²) that e holds, which is one for the
+// zero value of GT.
+func (e *GT) element() *gfP12 {
+	if e.p == nil {
+		return (&gfP12{}).SetOne()
+	}
+	return e.p
 }
 
 // RandomGT returns x and e(g₁, g₂)ˣ where x is a random, non-zero number read
@@ -321,154 +794,347 @@ func RandomGT(r io.Reader) (*big.Int, *GT, error) {
 	return k, new(GT).ScalarBaseMult(k), nil
 }
 
+// GeneratorGT returns the generator of GT, which is the pairing of the
+// generators of G₁ and G₂.
+func GeneratorGT() *GT {
+	return new(GT).SetGenerator()
+}
+
 // Pair calculates an Optimal Ate pairing.
 func Pair(g1 *G1, g2 *G2) *GT {
-	return &GT{optimalAte(g2.p, g1.p)}
+	return &GT{p: optimalAte(g2.point(), g1.point())}
+}
+
+// MultiPair calculates the product of the Optimal Ate pairings of g1s[i] and
+// g2s[i]. This is considerably faster than multiplying the results of Pair as
+// the Miller loops share their squarings and only one final exponentiation is
+// needed. It panics if g1s and g2s have different lengths.
+func MultiPair(g1s []*G1, g2s []*G2) *GT {
+	if len(g1s) != len(g2s) {
+		panic("bn256: mismatched number of G1 and G2 elements")
+	}
+
+	ps := make([]*curvePoint, len(g1s))
+	qs := make([]*twistPoint, len(g2s))
+	for i := range g1s {
+		ps[i], qs[i] = g1s[i].point(), g2s[i].point()
+	}
+
+	return &GT{p: finalExponentiation(multiMiller(qs, ps))}
+}
+
+// PairingCheck returns true iff the product of the Optimal Ate pairings of
+// g1s[i] and g2s[i] is the identity of GT. As with MultiPair, it panics if g1s
+// and g2s have different lengths.
+func PairingCheck(g1s []*G1, g2s []*G2) bool {
+	if len(g1s) != len(g2s) {
+		panic("bn256: mismatched number of G1 and G2 elements")
+	}
+
+	ps := make([]*curvePoint, len(g1s))
+	qs := make([]*twistPoint, len(g2s))
+	for i := range g1s {
+		ps[i], qs[i] = g1s[i].point(), g2s[i].point()
+	}
+
+	return finalExponentiation(multiMiller(qs, ps)).IsOne()
+}
+
+// PreparedG2 holds the line functions that the Miller loop computes for an
+// element of G2. Pairing against a PreparedG2 only requires evaluating those
+// lines at the element of G1, which saves work when the same element of G2 is
+// used in many pairings. A PreparedG2 is never modified after it is created,
+// so it is safe to use concurrently.
+type PreparedG2 struct {
+	lines []lineCoefficients
+}
+
+// NewPreparedG2 computes the line functions for g2.
+func NewPreparedG2(g2 *G2) *PreparedG2 {
+	return &PreparedG2{millerLines(g2.point())}
+}
+
+// PairPrepared calculates the Optimal Ate pairing of g1 and the element of G2
+// that prepared was created from.
+func PairPrepared(g1 *G1, prepared *PreparedG2) *GT {
+	ret := millerEval([][]lineCoefficients{prepared.lines}, []*curvePoint{g1.point()})
+	return &GT{p: finalExponentiation(ret)}
 }
 
 // Miller applies Miller's algorithm, which is a bilinear function from the
 // source groups to F_p^12. Miller(g1, g2).Finalize() is equivalent to Pair(g1,
 // g2).
 func Miller(g1 *G1, g2 *G2) *GT {
-	return &GT{miller(g2.p, g1.p)}
+	return &GT{miller(g2.point(), g1.point()), true}
 }
 
 func (g *GT) String() string {
-	return "bn256.GT" + g.p.String()
+	return "bn256.GT" + g.element().String()
 }
 
 // ScalarBaseMult sets e to g*k where g is the generator of the group and then
-// returns out.
+// returns out. Its timing does not depend on k, so k may be a private key.
 func (e *GT) ScalarBaseMult(k *big.Int) *GT {
 	if e.p == nil {
-		e.p = &gfP12{}
+		e.p = (&gfP12{}).SetOne()
 	}
-	e.p.Exp(gfP12Gen, k)
+	w := scalarWords(k)
+	e.p.ExpBase(&w)
+	e.unfinalized = false
 	return e
 }
 
-// ScalarMult sets e to a*k and then returns e.
+// ScalarMult sets e to a*k and then returns e. Unless a derives from the
+// output of Miller, k is reduced modulo Order first.
 func (e *GT) ScalarMult(a *GT, k *big.Int) *GT {
 	if e.p == nil {
-		e.p = &gfP12{}
+		e.p = (&gfP12{}).SetOne()
+	}
+	// k can be reduced modulo Order for elements of GT, but not for the
+	// output of Miller.
+	if ap := a.element(); !a.unfinalized {
+		e.p.ExpGLS(ap, k)
+	} else if k.Sign() < 0 {
+		e.p.Exp(ap, new(big.Int).Neg(k))
+		e.p.Invert(e.p)
+	} else {
+		e.p.Exp(ap, k)
+	}
+	e.unfinalized = a.unfinalized
+	return e
+}
+
+// ScalarMultCT sets e to a*k and then returns e. Unlike ScalarMult, its timing
+// does not depend on k, so k may be a private key. If a derives from the output
+// of Miller, k isn't reduced modulo Order and its length is not hidden.
+func (e *GT) ScalarMultCT(a *GT, k *big.Int) *GT {
+	if e.p == nil {
+		e.p = (&gfP12{}).SetOne()
+	}
+	if ap := a.element(); !a.unfinalized {
+		w := scalarWords(k)
+		e.p.ExpCT(ap, &w)
+	} else {
+		// As for ScalarMult, k can't be reduced modulo Order, and the output
+		// of Miller is not in the cyclotomic subgroup.
+		e.p.expCT(ap, bigWords(k), (*gfP12).Square)
+		inv := (&gfP12{}).Invert(e.p)
+		e.p.Cmov(inv, subtle.ConstantTimeEq(int32(k.Sign()), -1))
+	}
+	e.unfinalized = a.unfinalized
+	return e
+}
+
+// ScalarBaseMultScalar sets e to g*k where g is the generator of the group and
+// then returns e. Its timing does not depend on k.
+func (e *GT) ScalarBaseMultScalar(k *Scalar) *GT {
+	if e.p == nil {
+		e.p = (&gfP12{}).SetOne()
 	}
-	e.p.Exp(a.p, k)
+	w := k.words()
+	e.p.ExpBase(&w)
+	e.unfinalized = false
+	return e
+}
+
+// ScalarMultScalar sets e to a*k and then returns e. Its timing does not depend
+// on k.
+func (e *GT) ScalarMultScalar(a *GT, k *Scalar) *GT {
+	if e.p == nil {
+		e.p = (&gfP12{}).SetOne()
+	}
+	w := k.words()
+	if ap := a.element(); !a.unfinalized {
+		e.p.ExpCT(ap, &w)
+	} else {
+		e.p.expCT(ap, w[:], (*gfP12).Square)
+	}
+	e.unfinalized = a.unfinalized
 	return e
 }
 
 // Add sets e to a+b and then returns e.
 func (e *GT) Add(a, b *GT) *GT {
 	if e.p == nil {
-		e.p = &gfP12{}
+		e.p = (&gfP12{}).SetOne()
 	}
-	e.p.Mul(a.p, b.p)
+	e.p.Mul(a.element(), b.element())
+	e.unfinalized = a.unfinalized || b.unfinalized
 	return e
 }
 
 // Neg sets e to -a and then returns e.
 func (e *GT) Neg(a *GT) *GT {
 	if e.p == nil {
-		e.p = &gfP12{}
+		e.p = (&gfP12{}).SetOne()
+	}
+	e.p.Conjugate(a.element())
+	e.unfinalized = a.unfinalized
+	return e
+}
+
+// Sub sets e to a-b and then returns e.
+func (e *GT) Sub(a, b *GT) *GT {
+	negB := (&gfP12{}).Conjugate(b.element())
+	if e.p == nil {
+		e.p = (&gfP12{}).SetOne()
 	}
-	e.p.Conjugate(a.p)
+	e.p.Mul(a.element(), negB)
+	e.unfinalized = a.unfinalized || b.unfinalized
+	return e
+}
+
+// Double sets e to 2a and then returns e.
+func (e *GT) Double(a *GT) *GT {
+	if e.p == nil {
+		e.p = (&gfP12{}).SetOne()
+	}
+	e.p.Square(a.element())
+	e.unfinalized = a.unfinalized
 	return e
 }
 
 // Set sets e to a and then returns e.
 func (e *GT) Set(a *GT) *GT {
 	if e.p == nil {
-		e.p = &gfP12{}
+		e.p = (&gfP12{}).SetOne()
 	}
-	e.p.Set(a.p)
+	e.p.Set(a.element())
+	e.unfinalized = a.unfinalized
 	return e
 }
 
+// Equal returns true iff e and a are the same element of the group.
+func (e *GT) Equal(a *GT) bool {
+	return *e.element() == *a.element()
+}
+
+// IsIdentity returns true iff e is the identity element of the group.
+func (e *GT) IsIdentity() bool {
+	return e.element().IsOne()
+}
+
+// SetIdentity sets e to the identity element of the group and then returns e.
+func (e *GT) SetIdentity() *GT {
+	if e.p == nil {
+		e.p = (&gfP12{}).SetOne()
+	}
+	e.p.SetOne()
+	e.unfinalized = false
+	return e
+}
+
+// SetGenerator sets e to the generator of the group, e(g₁, g₂), and then
+// returns e.
+func (e *GT) SetGenerator() *GT {
+	if e.p == nil {
+		e.p = (&gfP12{}).SetOne()
+	}
+	e.p.Set(gfP12Gen)
+	e.unfinalized = false
+	return e
+}
+
+// IsInSubgroup returns true iff e is in the subgroup of order Order. Elements
+// decoded by Unmarshal are always in the subgroup, but the output of Miller
+// generally is not.
+func (e *GT) IsInSubgroup() bool {
+	if e.p == nil {
+		return true
+	}
+	return e.p.IsInSubgroup()
+}
+
 // Finalize is a linear function from F_p^12 to GT.
 func (e *GT) Finalize() *GT {
-	ret := finalExponentiation(e.p)
+	ret := finalExponentiation(e.element())
+	if e.p == nil {
+		e.p = &gfP12{}
+	}
 	e.p.Set(ret)
+	e.unfinalized = false
 	return e
 }
 
-// Marshal converts e into a byte slice.
+// Marshal converts e into a byte slice. It doesn't modify e, so it is safe to
+// call concurrently with other methods that only read e.
 func (e *GT) Marshal() []byte {
+	return e.appendMarshal(nil)
+}
+
+// appendMarshal appends the output of Marshal to b and returns the result.
+func (e *GT) appendMarshal(b []byte) []byte {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
 
-	if e.p == nil {
-		e.p = &gfP12{}
-		e.p.SetOne()
-	}
+	a := e.element()
 
-	ret := make([]byte, numBytes*12)
+	n := len(b)
+	b = append(b, make([]byte, numBytes*12)...)
+	ret := b[n:]
 	temp := &gfP{}
 
-	montDecode(temp, &e.p.x.x.x)
+	montDecode(temp, &a.x.x.x)
 	temp.Marshal(ret)
-	montDecode(temp, &e.p.x.x.y)
+	montDecode(temp, &a.x.x.y)
 	temp.Marshal(ret[numBytes:])
-	montDecode(temp, &e.p.x.y.x)
+	montDecode(temp, &a.x.y.x)
 	temp.Marshal(ret[2*numBytes:])
-	montDecode(temp, &e.p.x.y.y)
+	montDecode(temp, &a.x.y.y)
 	temp.Marshal(ret[3*numBytes:])
-	montDecode(temp, &e.p.x.z.x)
+	montDecode(temp, &a.x.z.x)
 	temp.Marshal(ret[4*numBytes:])
-	montDecode(temp, &e.p.x.z.y)
+	montDecode(temp, &a.x.z.y)
 	temp.Marshal(ret[5*numBytes:])
-	montDecode(temp, &e.p.y.x.x)
+	montDecode(temp, &a.y.x.x)
 	temp.Marshal(ret[6*numBytes:])
-	montDecode(temp, &e.p.y.x.y)
+	montDecode(temp, &a.y.x.y)
 	temp.Marshal(ret[7*numBytes:])
-	montDecode(temp, &e.p.y.y.x)
+	montDecode(temp, &a.y.y.x)
 	temp.Marshal(ret[8*numBytes:])
-	montDecode(temp, &e.p.y.y.y)
+	montDecode(temp, &a.y.y.y)
 	temp.Marshal(ret[9*numBytes:])
-	montDecode(temp, &e.p.y.z.x)
+	montDecode(temp, &a.y.z.x)
 	temp.Marshal(ret[10*numBytes:])
-	montDecode(temp, &e.p.y.z.y)
+	montDecode(temp, &a.y.z.y)
 	temp.Marshal(ret[11*numBytes:])
 
-	return ret
+	return b
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
-// a group element and then returns e.
+// a group element and then returns e. It returns an error if the result is not
+// an element of GT.
 func (e *GT) Unmarshal(m []byte) ([]byte, error) {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
 
 	if len(m) < 12*numBytes {
-		return nil, errors.New("bn256: not enough data")
+		return nil, newDecodeError("GT", len(m), ErrShortBuffer)
+	}
+
+	// Decode into f, so that e is unchanged if m is invalid.
+	f := &gfP12{}
+	coords := [12]*gfP{
+		&f.x.x.x, &f.x.x.y, &f.x.y.x, &f.x.y.y, &f.x.z.x, &f.x.z.y,
+		&f.y.x.x, &f.y.x.y, &f.y.y.x, &f.y.y.y, &f.y.z.x, &f.y.z.y,
+	}
+	for i, c := range coords {
+		if err := c.Unmarshal(m[i*numBytes:]); err != nil {
+			return nil, newDecodeError("GT", i*numBytes, err)
+		}
+		montEncode(c, c)
+	}
+
+	if !f.IsInSubgroup() {
+		return nil, newDecodeError("GT", 0, ErrNotInSubgroup)
 	}
 
 	if e.p == nil {
 		e.p = &gfP12{}
 	}
-
-	e.p.x.x.x.Unmarshal(m)
-	e.p.x.x.y.Unmarshal(m[numBytes:])
-	e.p.x.y.x.Unmarshal(m[2*numBytes:])
-	e.p.x.y.y.Unmarshal(m[3*numBytes:])
-	e.p.x.z.x.Unmarshal(m[4*numBytes:])
-	e.p.x.z.y.Unmarshal(m[5*numBytes:])
-	e.p.y.x.x.Unmarshal(m[6*numBytes:])
-	e.p.y.x.y.Unmarshal(m[7*numBytes:])
-	e.p.y.y.x.Unmarshal(m[8*numBytes:])
-	e.p.y.y.y.Unmarshal(m[9*numBytes:])
-	e.p.y.z.x.Unmarshal(m[10*numBytes:])
-	e.p.y.z.y.Unmarshal(m[11*numBytes:])
-	montEncode(&e.p.x.x.x, &e.p.x.x.x)
-	montEncode(&e.p.x.x.y, &e.p.x.x.y)
-	montEncode(&e.p.x.y.x, &e.p.x.y.x)
-	montEncode(&e.p.x.y.y, &e.p.x.y.y)
-	montEncode(&e.p.x.z.x, &e.p.x.z.x)
-	montEncode(&e.p.x.z.y, &e.p.x.z.y)
-	montEncode(&e.p.y.x.x, &e.p.y.x.x)
-	montEncode(&e.p.y.x.y, &e.p.y.x.y)
-	montEncode(&e.p.y.y.x, &e.p.y.y.x)
-	montEncode(&e.p.y.y.y, &e.p.y.y.y)
-	montEncode(&e.p.y.z.x, &e.p.y.z.x)
-	montEncode(&e.p.y.z.y, &e.p.y.z.y)
+	e.p.Set(f)
+	e.unfinalized = false
 
 	return m[12*numBytes:], nil
 }
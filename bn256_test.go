@@ -5,6 +5,13 @@ import (
 
 	"bytes"
 	"crypto/rand"
+	"encoding"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
 )
 
 func TestG1(t *testing.T) {
@@ -41,6 +48,180 @@ func TestG1Marshal(t *testing.T) {
 	}
 }
 
+func TestG1MarshalCompressed(t *testing.T) {
+	_, Ga, err := RandomG1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ma := Ga.MarshalCompressed()
+	if len(ma) != 33 {
+		t.Fatalf("wrong length: %d", len(ma))
+	}
+
+	Gb := new(G1)
+	if _, err = Gb.UnmarshalCompressed(ma); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ga.Marshal(), Gb.Marshal()) {
+		t.Fatal("bytes are different")
+	}
+
+	Gb.Neg(Ga)
+	mb := Gb.MarshalCompressed()
+	if ma[0] == mb[0] || !bytes.Equal(ma[1:], mb[1:]) {
+		t.Fatal("negation didn't flip the sign bit")
+	}
+
+	inf := new(G1).ScalarBaseMult(Order).MarshalCompressed()
+	if _, err = Gb.UnmarshalCompressed(inf); err != nil {
+		t.Fatal(err)
+	} else if !Gb.p.IsInfinity() {
+		t.Fatal("expected point at infinity")
+	}
+}
+
+func TestG1MarshalUniform(t *testing.T) {
+	// Among uniform 32-byte strings the top bit is set half the time, but among
+	// canonical field elements only about a tenth of the time.
+	topBits := 0
+	const n = 50
+	for i := 0; i < n; i++ {
+		_, Ga, err := RandomG1(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ma, err := Ga.MarshalUniform(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(ma) != 64 {
+			t.Fatalf("wrong length: %d", len(ma))
+		}
+		topBits += int(ma[0]>>7) + int(ma[32]>>7)
+
+		Gb := new(G1)
+		if _, err = Gb.UnmarshalUniform(ma); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(Ga.Marshal(), Gb.Marshal()) {
+			t.Fatal("bytes are different")
+		}
+	}
+	if topBits < n/2 || topBits > 3*n/2 {
+		t.Errorf("top bit set in %d of %d field elements", topBits, 2*n)
+	}
+
+	inf := new(G1).ScalarBaseMult(Order)
+	ma, err := inf.MarshalUniform(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Gb := new(G1)
+	if _, err = Gb.UnmarshalUniform(ma); err != nil {
+		t.Fatal(err)
+	} else if !Gb.p.IsInfinity() {
+		t.Fatal("expected point at infinity")
+	}
+}
+
+func TestG1UnmarshalCompressedMalformed(t *testing.T) {
+	_, Ga, err := RandomG1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ma := Ga.MarshalCompressed()
+
+	bad := append([]byte{}, ma...)
+	bad[0] = 0x04
+	if _, err := new(G1).UnmarshalCompressed(bad); err == nil {
+		t.Error("accepted invalid flag")
+	}
+
+	bad = make([]byte, 33)
+	bad[32] = 1
+	if _, err := new(G1).UnmarshalCompressed(bad); err == nil {
+		t.Error("accepted non-zero point at infinity")
+	}
+
+	bad = append([]byte{0x02}, p.FillBytes(make([]byte, 32))...)
+	if _, err := new(G1).UnmarshalCompressed(bad); err == nil {
+		t.Error("accepted x = p")
+	}
+
+	x := new(big.Int).Add(new(big.Int).SetBytes(ma[1:]), p)
+	if x.BitLen() <= 256 {
+		bad = append([]byte{ma[0]}, x.FillBytes(make([]byte, 32))...)
+		if _, err := new(G1).UnmarshalCompressed(bad); err == nil {
+			t.Error("accepted x + p")
+		}
+	}
+
+	// x = 0 gives y² = 3, which is not a square.
+	bad = make([]byte, 33)
+	bad[0] = 0x02
+	if _, err := new(G1).UnmarshalCompressed(bad); err == nil {
+		t.Error("accepted point not on the curve")
+	}
+
+	if _, err := new(G1).UnmarshalCompressed(ma[:32]); err == nil {
+		t.Error("accepted truncated input")
+	}
+}
+
+// slowMul sets c to a*scalar with plain double-and-add.
+func slowMul(a *curvePoint, scalar *big.Int) *curvePoint {
+	sum := &curvePoint{}
+	sum.SetInfinity()
+	for i := scalar.BitLen() - 1; i >= 0; i-- {
+		sum.Double(sum)
+		if scalar.Bit(i) != 0 {
+			sum.Add(sum, a)
+		}
+	}
+	return sum
+}
+
+// testScalars returns scalars that exercise the edge cases of scalar
+// multiplication, followed by n random ones.
+func testScalars(n int) []*big.Int {
+	scalars := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(2),
+		big.NewInt(-1),
+		new(big.Int).Sub(Order, big.NewInt(1)),
+		new(big.Int).Set(Order),
+		new(big.Int).Lsh(big.NewInt(1), 300),
+	}
+	for i := 0; i < n; i++ {
+		k, _ := rand.Int(rand.Reader, Order)
+		scalars = append(scalars, k)
+	}
+	return scalars
+}
+
+func TestG1ScalarMult(t *testing.T) {
+	scalars := testScalars(10)
+
+	_, Ga, err := RandomG1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range scalars {
+		ks := curveLattice.decompose(k)
+		for _, ki := range ks {
+			if ki.BitLen() > 129 {
+				t.Errorf("%v: decomposition too long: %v", k, ks)
+			}
+		}
+
+		got := new(G1).ScalarMult(Ga, k)
+		want := &G1{slowMul(Ga.p, new(big.Int).Mod(k, Order))}
+		if !bytes.Equal(got.Marshal(), want.Marshal()) {
+			t.Errorf("%v: bytes are different", k)
+		}
+	}
+}
+
 func TestG2(t *testing.T) {
 	k, Ga, err := RandomG2(rand.Reader)
 	if err != nil {
@@ -75,6 +256,125 @@ func TestG2Marshal(t *testing.T) {
 	}
 }
 
+func TestG2MarshalCompressed(t *testing.T) {
+	_, Ga, err := RandomG2(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ma := Ga.MarshalCompressed()
+	if len(ma) != 65 {
+		t.Fatalf("wrong length: %d", len(ma))
+	}
+
+	Gb := new(G2)
+	if _, err = Gb.UnmarshalCompressed(ma); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ga.Marshal(), Gb.Marshal()) {
+		t.Fatal("bytes are different")
+	}
+
+	Gb.Neg(Ga)
+	mb := Gb.MarshalCompressed()
+	if ma[0] == mb[0] || !bytes.Equal(ma[1:], mb[1:]) {
+		t.Fatal("negation didn't flip the sign bit")
+	}
+
+	inf := new(G2).ScalarBaseMult(Order).MarshalCompressed()
+	if _, err = Gb.UnmarshalCompressed(inf); err != nil {
+		t.Fatal(err)
+	} else if !Gb.p.IsInfinity() {
+		t.Fatal("expected point at infinity")
+	}
+
+	bad := append([]byte{}, ma...)
+	bad[0] = 0x01
+	if _, err := new(G2).UnmarshalCompressed(bad); err == nil {
+		t.Error("accepted invalid flag")
+	}
+
+	bad = append([]byte{}, ma...)
+	copy(bad[33:], p.FillBytes(make([]byte, 32)))
+	if _, err := new(G2).UnmarshalCompressed(bad); err == nil {
+		t.Error("accepted coordinate equal to p")
+	}
+}
+
+func TestG2ScalarMult(t *testing.T) {
+	scalars := testScalars(10)
+
+	_, Ga, err := RandomG2(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range scalars {
+		ks := frobeniusLattice.decompose(k)
+		for _, ki := range ks {
+			if ki.BitLen() > 66 {
+				t.Errorf("%v: decomposition too long: %v", k, ks)
+			}
+		}
+
+		got := new(G2).ScalarMult(Ga, k)
+		want := &G2{&twistPoint{}}
+		want.p.MulGeneric(Ga.p, new(big.Int).Mod(k, Order))
+		if !bytes.Equal(got.Marshal(), want.Marshal()) {
+			t.Errorf("%v: bytes are different", k)
+		}
+	}
+}
+
+// randomTwistPoint returns a random point on the twist, which is almost
+// certainly not in G₂.
+func randomTwistPoint(t *testing.T) *twistPoint {
+	for {
+		x := &gfP2{*togfP(randomGF(rand.Reader)), *togfP(randomGF(rand.Reader))}
+		y2 := (&gfP2{}).Square(x)
+		y2.Mul(y2, x).Add(y2, twistB)
+		y := (&gfP2{}).Sqrt(y2)
+		if s := (&gfP2{}).Square(y); *s != *y2 {
+			continue
+		}
+
+		pt := &twistPoint{x: *x, y: *y}
+		pt.z.SetOne()
+		pt.t.SetOne()
+		if !pt.IsOnCurve() {
+			t.Fatal("point is not on the twist")
+		}
+		return pt
+	}
+}
+
+func TestG2Subgroup(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		_, Ga, err := RandomG2(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Ga.IsInSubgroup() {
+			t.Fatal("point of G2 not in subgroup")
+		}
+
+		pt := randomTwistPoint(t)
+		Gb := &G2{pt}
+		if Gb.IsInSubgroup() {
+			t.Fatal("point outside G2 in subgroup")
+		}
+		if Gb.p.MulGeneric(pt, Order); Gb.p.IsInfinity() {
+			t.Fatal("random point has order dividing Order")
+		}
+
+		Gb.p.Set(pt)
+		if _, err := new(G2).Unmarshal(Gb.Marshal()); err == nil {
+			t.Fatal("Unmarshal accepted point outside G2")
+		}
+		if _, err := new(G2).UnmarshalCompressed(Gb.MarshalCompressed()); err == nil {
+			t.Fatal("UnmarshalCompressed accepted point outside G2")
+		}
+	}
+}
+
 func TestGT(t *testing.T) {
 	k, Ga, err := RandomGT(rand.Reader)
 	if err != nil {
@@ -83,7 +383,7 @@ func TestGT(t *testing.T) {
 	ma := Ga.Marshal()
 
 	G := new(GT)
-	_, err = G.Unmarshal((&GT{gfP12Gen}).Marshal())
+	_, err = G.Unmarshal((&GT{p: gfP12Gen}).Marshal())
 	if err != nil {
 		t.Fatal("unmarshal not ok")
 	}
@@ -114,6 +414,759 @@ func TestGTMarshal(t *testing.T) {
 	}
 }
 
+func TestCyclotomicSquare(t *testing.T) {
+	_, Ga, err := RandomGT(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The easy part of the final exponentiation maps any non-zero element
+	// into the cyclotomic subgroup.
+	_, g1, _ := RandomG1(rand.Reader)
+	_, g2, _ := RandomG2(rand.Reader)
+	m := Miller(g1, g2)
+	f := m.p
+	g := (&gfP12{}).Conjugate(f)
+	g.Mul(g, (&gfP12{}).Invert(f))
+	g.Mul(g, (&gfP12{}).FrobeniusP2(g))
+
+	for _, a := range []*gfP12{Ga.p, g, (&gfP12{}).SetOne()} {
+		if !a.IsCyclotomic() {
+			t.Fatal("element not in cyclotomic subgroup")
+		}
+		got := (&gfP12{}).Set(a)
+		got.CyclotomicSquare(got)
+		want := (&gfP12{}).Square(a)
+		if *got != *want {
+			t.Fatal("cyclotomic square differs from square")
+		}
+	}
+
+	k, _ := rand.Int(rand.Reader, Order)
+	if got, want := (&gfP12{}).CyclotomicExp(g, k), (&gfP12{}).Exp(g, k); *got != *want {
+		t.Fatal("cyclotomic exponentiation differs from exponentiation")
+	}
+	if f.IsCyclotomic() {
+		t.Fatal("output of Miller in cyclotomic subgroup")
+	}
+	if got, want := new(GT).ScalarMult(m, k), (&gfP12{}).Exp(f, k); *got.p != *want {
+		t.Fatal("ScalarMult of Miller output is wrong")
+	}
+
+	// g is in the cyclotomic subgroup but not in GT, so k can't be reduced
+	// modulo Order.
+	want := (&gfP12{}).Exp(g, k)
+	if got := new(GT).ScalarMult(&GT{g, true}, k); *got.p != *want {
+		t.Fatal("ScalarMult of cyclotomic element outside GT is wrong")
+	}
+	want.Invert(want)
+	if got := new(GT).ScalarMult(&GT{g, true}, new(big.Int).Neg(k)); *got.p != *want {
+		t.Fatal("ScalarMult of cyclotomic element outside GT by negative scalar is wrong")
+	}
+}
+
+func TestGTScalarMult(t *testing.T) {
+	scalars := testScalars(4)
+
+	_, Ga, err := RandomGT(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range scalars {
+		got := new(GT).ScalarMult(Ga, k)
+		want := (&gfP12{}).Exp(Ga.p, new(big.Int).Mod(k, Order))
+		if *got.p != *want {
+			t.Errorf("%v: values are different", k)
+		}
+	}
+}
+
+func TestGTSubgroup(t *testing.T) {
+	_, Ga, err := RandomGT(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Ga.IsInSubgroup() || !(&GT{p: gfP12Gen}).IsInSubgroup() {
+		t.Fatal("element of GT not in subgroup")
+	}
+
+	// A random element of GF(p¹²) isn't even in the cyclotomic subgroup.
+	a := &gfP12{}
+	for _, c := range []*gfP2{&a.x.x, &a.x.y, &a.x.z, &a.y.x, &a.y.y, &a.y.z} {
+		c.x.Set(togfP(randomGF(rand.Reader)))
+		c.y.Set(togfP(randomGF(rand.Reader)))
+	}
+	if a.IsCyclotomic() || a.IsInSubgroup() {
+		t.Fatal("random element in cyclotomic subgroup")
+	}
+	if _, err := new(GT).Unmarshal((&GT{p: a}).Marshal()); err == nil {
+		t.Fatal("Unmarshal accepted element outside cyclotomic subgroup")
+	}
+
+	// Raising it to the power (p⁶-1)(p²+1) moves it into the cyclotomic
+	// subgroup, but not into GT.
+	b := (&gfP12{}).Conjugate(a)
+	b.Mul(b, (&gfP12{}).Invert(a))
+	b.Mul(b, (&gfP12{}).FrobeniusP2(b))
+	if !b.IsCyclotomic() {
+		t.Fatal("element not in cyclotomic subgroup")
+	} else if b.IsInSubgroup() {
+		t.Fatal("element outside GT in subgroup")
+	}
+	if _, err := new(GT).Unmarshal((&GT{p: b}).Marshal()); err == nil {
+		t.Fatal("Unmarshal accepted element outside GT")
+	}
+
+	if _, err := new(GT).Unmarshal(make([]byte, 384)); err == nil {
+		t.Fatal("Unmarshal accepted zero")
+	}
+}
+
+func TestUnmarshalNonCanonical(t *testing.T) {
+	pMinus1 := new(big.Int).Sub(p, big.NewInt(1))
+	pPlus1 := new(big.Int).Add(p, big.NewInt(1))
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	sqrt2 := new(big.Int).ModSqrt(big.NewInt(2), p)
+	if sqrt2.Cmp(new(big.Int).Rsh(p, 1)) > 0 {
+		sqrt2.Sub(p, sqrt2)
+	}
+
+	enc := func(xs ...*big.Int) []byte {
+		out := []byte{}
+		for _, x := range xs {
+			out = append(out, x.FillBytes(make([]byte, 32))...)
+		}
+		return out
+	}
+
+	g1Tests := []struct {
+		x, y *big.Int
+		ok   bool
+	}{
+		{big.NewInt(0), big.NewInt(0), true},
+		{big.NewInt(1), new(big.Int).Sub(p, big.NewInt(2)), true},
+		{pMinus1, sqrt2, true},
+		{pPlus1, new(big.Int).Sub(p, big.NewInt(2)), false},
+		{pMinus1, new(big.Int).Add(sqrt2, p), false},
+		{p, big.NewInt(0), false},
+		{big.NewInt(0), p, false},
+		{max, max, false},
+	}
+	for i, tc := range g1Tests {
+		_, err := new(G1).Unmarshal(enc(tc.x, tc.y))
+		if (err == nil) != tc.ok {
+			t.Errorf("G1 case %d: got err=%v, want ok=%v", i, err, tc.ok)
+		}
+	}
+
+	_, Ga, err := RandomG2(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ma := Ga.Marshal()
+	for i := 0; i < 4; i++ {
+		for _, x := range []*big.Int{p, pPlus1, max} {
+			mb := append([]byte{}, ma...)
+			copy(mb[1+32*i:], enc(x))
+			if _, err := new(G2).Unmarshal(mb); err == nil {
+				t.Errorf("G2 coordinate %d: accepted %v", i, x)
+			}
+		}
+
+		// Adding p to a coordinate would give the same point after reduction.
+		x := new(big.Int).SetBytes(ma[1+32*i : 1+32*(i+1)])
+		if x.Add(x, p).BitLen() <= 256 {
+			mb := append([]byte{}, ma...)
+			copy(mb[1+32*i:], enc(x))
+			if _, err := new(G2).Unmarshal(mb); err == nil {
+				t.Errorf("G2 coordinate %d: accepted unreduced value", i)
+			}
+		}
+	}
+
+	// The point at infinity is only encoded as 0x00.
+	if _, err := new(G2).Unmarshal([]byte{0x00}); err != nil {
+		t.Errorf("G2: rejected 0x00: %v", err)
+	}
+	if _, err := new(G2).Unmarshal(append([]byte{0x01}, make([]byte, 128)...)); !errors.Is(err, ErrNonCanonical) {
+		t.Errorf("G2: got err=%v for 0x01 and zero coordinates, want ErrNonCanonical", err)
+	}
+
+	mc := append([]byte{0x02}, enc(pMinus1)...)
+	if _, err := new(G1).UnmarshalCompressed(mc); err != nil {
+		t.Errorf("G1 compressed: rejected x = p-1: %v", err)
+	}
+	mc = append([]byte{0x02}, enc(pPlus1)...)
+	if _, err := new(G1).UnmarshalCompressed(mc); err == nil {
+		t.Error("G1 compressed: accepted x = p+1")
+	}
+
+	_, Gt, err := RandomGT(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := Gt.Marshal()
+	for i := 0; i < 12; i++ {
+		x := new(big.Int).SetBytes(mt[32*i : 32*(i+1)])
+		for _, y := range []*big.Int{p, max, x.Add(x, p)} {
+			if y.BitLen() > 256 {
+				continue
+			}
+			mb := append([]byte{}, mt...)
+			copy(mb[32*i:], enc(y))
+			if _, err := new(GT).Unmarshal(mb); err == nil {
+				t.Errorf("GT coordinate %d: accepted %v", i, y)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	enc := func(x *big.Int) []byte { return x.FillBytes(make([]byte, 32)) }
+	g1 := GeneratorG1().Marshal()
+	g2 := GeneratorG2().Marshal()
+	gt := GeneratorGT().Marshal()
+
+	notOnCurve := append(enc(big.NewInt(1)), enc(big.NewInt(1))...)
+	infWithX := make([]byte, 33)
+	infWithX[10] = 1
+	// x = 0 has x³+3 = 3, which is not a square.
+	compressedNotOnCurve := append([]byte{0x02}, enc(big.NewInt(0))...)
+
+	twist := &G2{mapToTwist(&gfP2{*newGFp(1), *newGFp(2)})}
+	two := &GT{p: (&gfP12{}).SetOne()}
+	gfpAdd(&two.p.y.z.y, &two.p.y.z.y, &two.p.y.z.y)
+
+	unmarshal := func(group string) func([]byte) ([]byte, error) {
+		switch group {
+		case "G1":
+			return new(G1).Unmarshal
+		case "G1c":
+			return new(G1).UnmarshalCompressed
+		case "G2":
+			return new(G2).Unmarshal
+		case "G2c":
+			return new(G2).UnmarshalCompressed
+		case "GT":
+			return new(GT).Unmarshal
+		}
+		return new(Scalar).Unmarshal
+	}
+
+	tests := []struct {
+		decoder string
+		in      []byte
+		want    error
+		group   string
+		offset  int
+	}{
+		{"G1", g1[:63], ErrShortBuffer, "G1", 63},
+		{"G1", append(enc(p), g1[32:]...), ErrNonCanonical, "G1", 0},
+		{"G1", append(g1[:32:32], enc(p)...), ErrNonCanonical, "G1", 32},
+		{"G1", notOnCurve, ErrNotOnCurve, "G1", 0},
+		{"G1c", []byte{0x02}, ErrShortBuffer, "G1", 1},
+		{"G1c", append([]byte{0x04}, g1[:32]...), ErrInvalidFlag, "G1", 0},
+		{"G1c", infWithX, ErrNonCanonical, "G1", 10},
+		{"G1c", append([]byte{0x02}, enc(p)...), ErrNonCanonical, "G1", 1},
+		{"G1c", compressedNotOnCurve, ErrNotOnCurve, "G1", 1},
+		{"G2", nil, ErrShortBuffer, "G2", 0},
+		{"G2", append([]byte{0x02}, g2[1:]...), ErrInvalidFlag, "G2", 0},
+		{"G2", append(g2[:33:33], append(enc(p), g2[65:]...)...), ErrNonCanonical, "G2", 33},
+		{"G2", twist.Marshal(), ErrNotInSubgroup, "G2", 1},
+		{"G2c", twist.MarshalCompressed(), ErrNotInSubgroup, "G2", 1},
+		{"G2c", append([]byte{0x01}, make([]byte, 64)...), ErrInvalidFlag, "G2", 0},
+		{"GT", gt[:100], ErrShortBuffer, "GT", 100},
+		{"GT", append(gt[:64:64], append(enc(p), gt[96:]...)...), ErrNonCanonical, "GT", 64},
+		{"GT", two.Marshal(), ErrNotInSubgroup, "GT", 0},
+		{"Scalar", enc(Order), ErrNonCanonical, "Scalar", 0},
+		{"Scalar", make([]byte, 31), ErrShortBuffer, "Scalar", 31},
+	}
+	for i, tc := range tests {
+		_, err := unmarshal(tc.decoder)(tc.in)
+		var de *DecodeError
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%d: got %v, want %v", i, err, tc.want)
+		} else if !errors.As(err, &de) {
+			t.Errorf("%d: %v is not a *DecodeError", i, err)
+		} else if de.Group != tc.group || de.Offset != tc.offset {
+			t.Errorf("%d: got group %s and offset %d, want %s and %d", i, de.Group, de.Offset, tc.group, tc.offset)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	var (
+		_ encoding.BinaryMarshaler   = &G1{}
+		_ encoding.BinaryUnmarshaler = &G2{}
+		_ encoding.TextMarshaler     = &GT{}
+		_ encoding.TextUnmarshaler   = &G1{}
+		_ json.Marshaler             = &G2{}
+		_ json.Unmarshaler           = &GT{}
+	)
+
+	type keys struct {
+		A *G1
+		B *G2
+		C *GT
+		D *G1
+	}
+	_, a, _ := RandomG1(rand.Reader)
+	_, b, _ := RandomG2(rand.Reader)
+	_, c, _ := RandomGT(rand.Reader)
+	in := keys{a, b, c, new(G1)}
+
+	js, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"` + hex.EncodeToString(a.Marshal()) + `"`
+	if !bytes.Contains(js, []byte(want)) {
+		t.Fatalf("JSON %s doesn't hold %s", js, want)
+	}
+	var out keys
+	if err := json.Unmarshal(js, &out); err != nil {
+		t.Fatal(err)
+	} else if !out.A.Equal(a) || !out.B.Equal(b) || !out.C.Equal(c) || !out.D.IsIdentity() {
+		t.Fatal("JSON round trip changed the elements")
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	out = keys{}
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	} else if !out.A.Equal(a) || !out.B.Equal(b) || !out.C.Equal(c) || !out.D.IsIdentity() {
+		t.Fatal("gob round trip changed the elements")
+	}
+
+	text, _ := b.MarshalText()
+	if err := new(G2).UnmarshalText(append(text, 'a', 'a')); !errors.Is(err, ErrNonCanonical) {
+		t.Errorf("UnmarshalText accepted trailing data: %v", err)
+	}
+	upper := bytes.ToUpper(text)
+	i := bytes.IndexAny(text, "abcdef")
+	var de *DecodeError
+	if err := new(G2).UnmarshalText(upper); !errors.Is(err, ErrNonCanonical) || !errors.As(err, &de) || de.Offset != i {
+		t.Errorf("UnmarshalText accepted uppercase: got %v, want an error at offset %d", err, i)
+	}
+	text[10] = 'x'
+	if err := new(G2).UnmarshalText(text); !errors.As(err, &de) || de.Offset != 10 {
+		t.Errorf("UnmarshalText: got %v, want an error at offset 10", err)
+	}
+	if err := new(G1).UnmarshalBinary(append(a.Marshal(), 0)); !errors.Is(err, ErrNonCanonical) {
+		t.Errorf("UnmarshalBinary accepted trailing data: %v", err)
+	}
+
+	dst := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(10, func() {
+		dst, _ = a.AppendBinary(dst[:0])
+		dst, _ = b.AppendBinary(dst)
+		dst, _ = c.AppendBinary(dst)
+	})
+	if allocs != 0 {
+		t.Errorf("AppendBinary allocated %v times", allocs)
+	}
+	if !bytes.Equal(dst, append(append(a.Marshal(), b.Marshal()...), c.Marshal()...)) {
+		t.Error("AppendBinary doesn't match Marshal")
+	}
+}
+
+func TestMultiScalarMult(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 70} {
+		g1s, g2s := make([]*G1, n), make([]*G2, n)
+		scalars := make([]*big.Int, n)
+		want1, want2 := new(G1).ScalarBaseMult(Order), new(G2).ScalarBaseMult(Order)
+		for i := 0; i < n; i++ {
+			scalars[i], _ = rand.Int(rand.Reader, Order)
+			switch i % 7 {
+			case 1:
+				scalars[i].Neg(scalars[i])
+			case 2:
+				scalars[i].Add(scalars[i], Order)
+			case 3:
+				scalars[i].SetInt64(0)
+			}
+
+			if i%5 == 4 {
+				g1s[i], g2s[i] = g1s[i-1], g2s[i-1]
+			} else {
+				_, g1s[i], _ = RandomG1(rand.Reader)
+				_, g2s[i], _ = RandomG2(rand.Reader)
+			}
+			if i == 6 {
+				g1s[i], g2s[i] = new(G1).ScalarBaseMult(Order), new(G2).ScalarBaseMult(Order)
+			}
+
+			k := new(big.Int).Mod(scalars[i], Order)
+			want1.Add(want1, new(G1).ScalarMult(g1s[i], k))
+			want2.Add(want2, new(G2).ScalarMult(g2s[i], k))
+		}
+
+		got1 := new(G1).MultiScalarMult(g1s, scalars)
+		if !bytes.Equal(got1.Marshal(), want1.Marshal()) {
+			t.Errorf("G1, %d points: bytes are different", n)
+		}
+		got2 := new(G2).MultiScalarMult(g2s, scalars)
+		if !bytes.Equal(got2.Marshal(), want2.Marshal()) {
+			t.Errorf("G2, %d points: bytes are different", n)
+		}
+	}
+}
+
+func TestAddMixed(t *testing.T) {
+	_, a1, _ := RandomG1(rand.Reader)
+	_, b1, _ := RandomG1(rand.Reader)
+	b1.p.MakeAffine()
+	c1 := &curvePoint{}
+	c1.AddMixed(a1.p, b1.p)
+	want1 := &curvePoint{}
+	want1.Add(a1.p, b1.p)
+	if !c1.Equal(want1) {
+		t.Error("G1: sum is wrong")
+	}
+	z2 := &gfP{}
+	gfpMul(z2, &c1.z, &c1.z)
+	if *z2 != c1.t {
+		t.Error("G1: t is not z²")
+	}
+
+	_, a2, _ := RandomG2(rand.Reader)
+	_, b2, _ := RandomG2(rand.Reader)
+	b2.p.MakeAffine()
+	c2 := &twistPoint{}
+	c2.AddMixed(a2.p, b2.p)
+	want2 := &twistPoint{}
+	want2.Add(a2.p, b2.p)
+	if !c2.Equal(want2) {
+		t.Error("G2: sum is wrong")
+	}
+	if zz := (&gfP2{}).Square(&c2.z); *zz != c2.t {
+		t.Error("G2: t is not z²")
+	}
+}
+
+func TestScalarBaseMult(t *testing.T) {
+	scalars := testScalars(4)
+
+	g1 := &G1{curveGen}
+	g2 := &G2{twistGen}
+	gt := &GT{p: gfP12Gen}
+	for _, k := range scalars {
+		if got, want := new(G1).ScalarBaseMult(k), new(G1).ScalarMult(g1, k); !bytes.Equal(got.Marshal(), want.Marshal()) {
+			t.Errorf("G1, %v: bytes are different", k)
+		}
+		if got, want := new(G2).ScalarBaseMult(k), new(G2).ScalarMult(g2, k); !bytes.Equal(got.Marshal(), want.Marshal()) {
+			t.Errorf("G2, %v: bytes are different", k)
+		}
+		if got, want := new(GT).ScalarBaseMult(k), new(GT).ScalarMult(gt, new(big.Int).Mod(k, Order)); !bytes.Equal(got.Marshal(), want.Marshal()) {
+			t.Errorf("GT, %v: bytes are different", k)
+		}
+	}
+}
+
+func TestScalarMultCT(t *testing.T) {
+	scalars := testScalars(4)
+
+	_, g1, _ := RandomG1(rand.Reader)
+	_, g2, _ := RandomG2(rand.Reader)
+	_, gt, _ := RandomGT(rand.Reader)
+	inf1, inf2 := new(G1).ScalarBaseMult(Order), new(G2).ScalarBaseMult(Order)
+
+	// Neither the output of Miller nor c, which is in the cyclotomic subgroup,
+	// is in GT.
+	m := Miller(g1, g2)
+	c := &GT{(&gfP12{}).Conjugate(m.p), true}
+	c.p.Mul(c.p, (&gfP12{}).Invert(m.p))
+	c.p.Mul(c.p, (&gfP12{}).FrobeniusP2(c.p))
+
+	for _, k := range scalars {
+		for _, a := range []*G1{g1, inf1} {
+			if got, want := new(G1).ScalarMultCT(a, k), new(G1).ScalarMult(a, k); !bytes.Equal(got.Marshal(), want.Marshal()) {
+				t.Errorf("G1, %v: bytes are different", k)
+			}
+		}
+		for _, a := range []*G2{g2, inf2} {
+			if got, want := new(G2).ScalarMultCT(a, k), new(G2).ScalarMult(a, k); !bytes.Equal(got.Marshal(), want.Marshal()) {
+				t.Errorf("G2, %v: bytes are different", k)
+			}
+		}
+		for _, a := range []*GT{gt, m, c} {
+			if got, want := new(GT).ScalarMultCT(a, k), new(GT).ScalarMult(a, k); !bytes.Equal(got.Marshal(), want.Marshal()) {
+				t.Errorf("GT, %v: bytes are different", k)
+			}
+		}
+	}
+}
+
+func TestScalar(t *testing.T) {
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Sub(Order, big.NewInt(1)),
+	}
+	for i := 0; i < 8; i++ {
+		k, _ := rand.Int(rand.Reader, Order)
+		values = append(values, k)
+	}
+
+	// SetBigInt reduces values that are negative or at least Order.
+	wide, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 700))
+	for _, x := range append(testScalars(0), wide, new(big.Int).Neg(wide), new(big.Int).Neg(Order)) {
+		want := new(big.Int).Mod(x, Order)
+		if got := new(Scalar).SetBigInt(x).BigInt(); got.Cmp(want) != 0 {
+			t.Errorf("SetBigInt(%v) = %v, want %v", x, got, want)
+		}
+	}
+
+	c, want := new(Scalar), new(big.Int)
+	for _, x := range values {
+		a := new(Scalar).SetBigInt(x)
+		if got := a.BigInt(); got.Cmp(x) != 0 {
+			t.Errorf("SetBigInt(%v) = %v", x, got)
+		}
+
+		m := a.Marshal()
+		if rest, err := c.Unmarshal(m); err != nil || len(rest) != 0 || !c.Equal(a) {
+			t.Errorf("%v: Unmarshal failed", x)
+		}
+
+		if got, want := c.Neg(a).BigInt(), want.Neg(x).Mod(want, Order); got.Cmp(want) != 0 {
+			t.Errorf("-%v: got %v want %v", x, got, want)
+		}
+		if x.Sign() != 0 {
+			if got, want := c.Invert(a).BigInt(), want.ModInverse(x, Order); got.Cmp(want) != 0 {
+				t.Errorf("1/%v: got %v want %v", x, got, want)
+			}
+		} else if !c.Invert(a).IsZero() {
+			t.Error("1/0 is not 0")
+		}
+
+		for _, y := range values {
+			b := new(Scalar).SetBigInt(y)
+			if got, want := c.Add(a, b).BigInt(), want.Add(x, y).Mod(want, Order); got.Cmp(want) != 0 {
+				t.Errorf("%v+%v: got %v want %v", x, y, got, want)
+			}
+			if got, want := c.Sub(a, b).BigInt(), want.Sub(x, y).Mod(want, Order); got.Cmp(want) != 0 {
+				t.Errorf("%v-%v: got %v want %v", x, y, got, want)
+			}
+			if got, want := c.Mul(a, b).BigInt(), want.Mul(x, y).Mod(want, Order); got.Cmp(want) != 0 {
+				t.Errorf("%v*%v: got %v want %v", x, y, got, want)
+			}
+		}
+	}
+
+	var buf [48]byte
+	for i := 0; i < 8; i++ {
+		rand.Read(buf[:])
+		if i == 0 {
+			for j := range buf {
+				buf[j] = 0xff
+			}
+		}
+		want.SetBytes(buf[:]).Mod(want, Order)
+		if got := new(Scalar).setWideBytes(&buf).BigInt(); got.Cmp(want) != 0 {
+			t.Errorf("%x: got %v want %v", buf, got, want)
+		}
+	}
+
+	if got := new(Scalar).SetUint64(12345).BigInt(); got.Int64() != 12345 {
+		t.Errorf("SetUint64: got %v", got)
+	}
+	if _, err := new(Scalar).Unmarshal(Order.FillBytes(make([]byte, 32))); err == nil {
+		t.Error("Unmarshal accepted Order")
+	}
+	if _, err := new(Scalar).Unmarshal(make([]byte, 31)); err == nil {
+		t.Error("Unmarshal accepted short input")
+	}
+}
+
+func TestScalarMultScalar(t *testing.T) {
+	k, err := RandomScalar(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := k.BigInt()
+
+	_, g1, _ := RandomG1(rand.Reader)
+	_, g2, _ := RandomG2(rand.Reader)
+	_, gt, _ := RandomGT(rand.Reader)
+
+	if got, want := new(G1).ScalarBaseMultScalar(k), new(G1).ScalarBaseMult(x); !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Error("G1 base: bytes are different")
+	}
+	if got, want := new(G1).ScalarMultScalar(g1, k), new(G1).ScalarMult(g1, x); !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Error("G1: bytes are different")
+	}
+	if got, want := new(G2).ScalarBaseMultScalar(k), new(G2).ScalarBaseMult(x); !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Error("G2 base: bytes are different")
+	}
+	if got, want := new(G2).ScalarMultScalar(g2, k), new(G2).ScalarMult(g2, x); !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Error("G2: bytes are different")
+	}
+	if got, want := new(GT).ScalarBaseMultScalar(k), new(GT).ScalarBaseMult(x); !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Error("GT base: bytes are different")
+	}
+	if got, want := new(GT).ScalarMultScalar(gt, k), new(GT).ScalarMult(gt, x); !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Error("GT: bytes are different")
+	}
+	m := Miller(g1, g2)
+	if got, want := new(GT).ScalarMultScalar(m, k), new(GT).ScalarMult(m, x); !bytes.Equal(got.Marshal(), want.Marshal()) {
+		t.Error("Miller: bytes are different")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	k, _ := rand.Int(rand.Reader, Order)
+
+	// a is in affine form and b, computed differently, is not.
+	a1 := new(G1).ScalarBaseMult(k)
+	a1.p.MakeAffine()
+	b1 := new(G1).ScalarMult(GeneratorG1(), k)
+	b1.Add(b1, GeneratorG1()).Add(b1, new(G1).Neg(GeneratorG1()))
+	if b1.p.z == *newGFp(1) {
+		t.Fatal("expected a point in projective form")
+	}
+	if !a1.Equal(b1) || !b1.Equal(a1) {
+		t.Error("equal elements of G1 compare different")
+	} else if a1.Equal(GeneratorG1()) || a1.Equal(new(G1).Neg(a1)) {
+		t.Error("different elements of G1 compare equal")
+	}
+	if !new(G1).ScalarBaseMult(big.NewInt(1)).Equal(GeneratorG1()) {
+		t.Error("wrong generator of G1")
+	}
+	if !new(G1).ScalarBaseMult(Order).IsIdentity() || !new(G1).SetIdentity().Equal(new(G1).ScalarBaseMult(Order)) {
+		t.Error("wrong identity of G1")
+	} else if a1.IsIdentity() || a1.Equal(new(G1).SetIdentity()) {
+		t.Error("non-identity element of G1 is the identity")
+	}
+
+	a2 := new(G2).ScalarBaseMult(k)
+	a2.p.MakeAffine()
+	b2 := new(G2).ScalarMult(GeneratorG2(), k)
+	b2.Add(b2, GeneratorG2()).Add(b2, new(G2).Neg(GeneratorG2()))
+	if !a2.Equal(b2) || !b2.Equal(a2) {
+		t.Error("equal elements of G2 compare different")
+	} else if a2.Equal(GeneratorG2()) || a2.Equal(new(G2).Neg(a2)) {
+		t.Error("different elements of G2 compare equal")
+	}
+	if !new(G2).ScalarBaseMult(big.NewInt(1)).Equal(GeneratorG2()) {
+		t.Error("wrong generator of G2")
+	}
+	if !new(G2).ScalarBaseMult(Order).IsIdentity() || !new(G2).SetIdentity().Equal(new(G2).ScalarBaseMult(Order)) {
+		t.Error("wrong identity of G2")
+	} else if a2.IsIdentity() {
+		t.Error("non-identity element of G2 is the identity")
+	}
+
+	at := new(GT).ScalarBaseMult(k)
+	if !at.Equal(Pair(a1, GeneratorG2())) || at.Equal(GeneratorGT()) {
+		t.Error("wrong result of GT.Equal")
+	}
+	if !Pair(GeneratorG1(), GeneratorG2()).Equal(GeneratorGT()) {
+		t.Error("wrong generator of GT")
+	}
+	if !new(GT).ScalarBaseMult(Order).IsIdentity() || !new(GT).SetIdentity().Equal(new(GT).ScalarBaseMult(Order)) {
+		t.Error("wrong identity of GT")
+	} else if at.IsIdentity() {
+		t.Error("non-identity element of GT is the identity")
+	}
+}
+
+func TestSubDouble(t *testing.T) {
+	_, a1, _ := RandomG1(rand.Reader)
+	_, b1, _ := RandomG1(rand.Reader)
+	if !new(G1).Sub(a1, b1).Equal(new(G1).Add(a1, new(G1).Neg(b1))) {
+		t.Error("wrong result of G1.Sub")
+	} else if !new(G1).Double(a1).Equal(new(G1).Add(a1, a1)) {
+		t.Error("wrong result of G1.Double")
+	} else if !new(G1).Sub(a1, a1).IsIdentity() {
+		t.Error("a-a is not the identity of G1")
+	}
+
+	_, a2, _ := RandomG2(rand.Reader)
+	_, b2, _ := RandomG2(rand.Reader)
+	if !new(G2).Sub(a2, b2).Equal(new(G2).Add(a2, new(G2).Neg(b2))) {
+		t.Error("wrong result of G2.Sub")
+	} else if !new(G2).Double(a2).Equal(new(G2).Add(a2, a2)) {
+		t.Error("wrong result of G2.Double")
+	} else if !new(G2).Sub(a2, a2).IsIdentity() {
+		t.Error("a-a is not the identity of G2")
+	}
+
+	_, at, _ := RandomGT(rand.Reader)
+	_, bt, _ := RandomGT(rand.Reader)
+	if !new(GT).Sub(at, bt).Equal(new(GT).Add(at, new(GT).Neg(bt))) {
+		t.Error("wrong result of GT.Sub")
+	} else if !new(GT).Double(at).Equal(new(GT).Add(at, at)) {
+		t.Error("wrong result of GT.Double")
+	} else if !new(GT).Sub(at, at).IsIdentity() {
+		t.Error("a-a is not the identity of GT")
+	}
+
+	// The result may alias an input.
+	a1.Sub(a1, a1)
+	a2.Double(a2).Sub(a2, a2)
+	at.Double(at).Sub(at, at)
+	if !a1.IsIdentity() || !a2.IsIdentity() || !at.IsIdentity() {
+		t.Error("wrong result with aliased arguments")
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	k := big.NewInt(12345)
+	g1, g2, gt := GeneratorG1(), GeneratorG2(), GeneratorGT()
+
+	if !bytes.Equal(new(G1).Marshal(), new(G1).SetIdentity().Marshal()) ||
+		!bytes.Equal(new(G2).Marshal(), new(G2).SetIdentity().Marshal()) ||
+		!bytes.Equal(new(GT).Marshal(), new(GT).SetIdentity().Marshal()) {
+		t.Error("zero value doesn't marshal as the identity")
+	}
+
+	if !new(G1).Add(&G1{}, g1).Equal(g1) || !new(G1).Add(g1, &G1{}).Equal(g1) ||
+		!new(G1).Sub(&G1{}, g1).Equal(new(G1).Neg(g1)) || !new(G1).Double(&G1{}).IsIdentity() ||
+		!new(G1).ScalarMult(&G1{}, k).IsIdentity() || !new(G1).ScalarMultCT(&G1{}, k).IsIdentity() ||
+		!new(G1).Neg(&G1{}).IsIdentity() || !new(G1).Set(&G1{}).IsIdentity() {
+		t.Error("zero value of G1 doesn't act as the identity")
+	}
+	if !new(G2).Add(&G2{}, g2).Equal(g2) || !new(G2).Add(g2, &G2{}).Equal(g2) ||
+		!new(G2).Sub(&G2{}, g2).Equal(new(G2).Neg(g2)) || !new(G2).Double(&G2{}).IsIdentity() ||
+		!new(G2).ScalarMult(&G2{}, k).IsIdentity() || !new(G2).ScalarMultCT(&G2{}, k).IsIdentity() ||
+		!new(G2).Neg(&G2{}).IsIdentity() || !new(G2).Set(&G2{}).IsIdentity() {
+		t.Error("zero value of G2 doesn't act as the identity")
+	}
+	if !new(GT).Add(&GT{}, gt).Equal(gt) || !new(GT).Add(gt, &GT{}).Equal(gt) ||
+		!new(GT).Sub(&GT{}, gt).Equal(new(GT).Neg(gt)) || !new(GT).Double(&GT{}).IsIdentity() ||
+		!new(GT).ScalarMult(&GT{}, k).IsIdentity() || !new(GT).ScalarMultCT(&GT{}, k).IsIdentity() ||
+		!new(GT).Neg(&GT{}).IsIdentity() || !new(GT).Set(&GT{}).IsIdentity() {
+		t.Error("zero value of GT doesn't act as the identity")
+	}
+
+	if !Pair(&G1{}, g2).IsIdentity() || !Pair(g1, &G2{}).IsIdentity() || !Miller(&G1{}, g2).Finalize().IsIdentity() {
+		t.Error("pairing with the zero value isn't the identity")
+	} else if !PairingCheck([]*G1{{}, g1}, []*G2{g2, {}}) || !MultiPair([]*G1{{}}, []*G2{{}}).IsIdentity() {
+		t.Error("pairing with the zero value isn't the identity")
+	} else if !PairPrepared(&G1{}, NewPreparedG2(g2)).IsIdentity() || !PairPrepared(g1, NewPreparedG2(&G2{})).IsIdentity() {
+		t.Error("prepared pairing with the zero value isn't the identity")
+	}
+	if !new(G1).MultiScalarMult([]*G1{{}, g1}, []*big.Int{k, k}).Equal(new(G1).ScalarMult(g1, k)) {
+		t.Error("zero value in MultiScalarMult isn't the identity")
+	}
+
+	// A zero value can also be both the output and an input.
+	var x1 G1
+	var x2 G2
+	var xt GT
+	x1.Add(&x1, g1)
+	x2.Add(&x2, g2)
+	xt.Add(&xt, gt)
+	if !x1.Equal(g1) || !x2.Equal(g2) || !xt.Equal(gt) {
+		t.Error("wrong result with aliased zero value")
+	}
+	_, _, _ = new(G1).String(), new(G2).String(), new(GT).String()
+}
+
 func TestBilinearity(t *testing.T) {
 	for i := 0; i < 2; i++ {
 		a, p1, _ := RandomG1(rand.Reader)
@@ -130,6 +1183,107 @@ func TestBilinearity(t *testing.T) {
 	}
 }
 
+func TestMultiPair(t *testing.T) {
+	g1s, g2s := make([]*G1, 4), make([]*G2, 4)
+	want := new(GT).ScalarBaseMult(big.NewInt(0))
+	for i := range g1s {
+		_, g1s[i], _ = RandomG1(rand.Reader)
+		_, g2s[i], _ = RandomG2(rand.Reader)
+		want.Add(want, Pair(g1s[i], g2s[i]))
+	}
+
+	// Pairs involving the point at infinity shouldn't change the result.
+	g1s = append(g1s, new(G1).ScalarBaseMult(Order), g1s[0])
+	g2s = append(g2s, g2s[0], new(G2).ScalarBaseMult(Order))
+
+	got := MultiPair(g1s, g2s)
+	if *got.p != *want.p {
+		t.Fatal("MultiPair doesn't match the product of pairings")
+	}
+
+	if got := MultiPair(nil, nil); !got.p.IsOne() {
+		t.Fatal("empty product isn't one")
+	}
+}
+
+func TestPairingCheck(t *testing.T) {
+	a, Ga, err := RandomG1(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, Gb, err := RandomG2(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// e(g₁ᵃ, g₂ᵇ)·e(-g₁ᵃᵇ, g₂) = 1
+	ab := new(big.Int).Mul(a, b)
+	Gc := new(G1).ScalarBaseMult(ab)
+	Gc.Neg(Gc)
+
+	if !PairingCheck([]*G1{Ga, Gc}, []*G2{Gb, {twistGen}}) {
+		t.Fatal("valid equation rejected")
+	}
+	if PairingCheck([]*G1{Ga, Ga}, []*G2{Gb, {twistGen}}) {
+		t.Fatal("invalid equation accepted")
+	}
+	if !PairingCheck(nil, nil) {
+		t.Fatal("empty product rejected")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("mismatched lengths didn't panic")
+		}
+	}()
+	PairingCheck([]*G1{Ga, Gc}, []*G2{Gb})
+}
+
+func TestPairPrepared(t *testing.T) {
+	_, Gb, err := RandomG2(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared := NewPreparedG2(Gb)
+
+	for i := 0; i < 2; i++ {
+		_, Ga, err := RandomG1(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e1 := PairPrepared(Ga, prepared)
+		e2 := Pair(Ga, Gb)
+		if *e1.p != *e2.p {
+			t.Fatal("PairPrepared doesn't match Pair")
+		}
+	}
+
+	if e := PairPrepared(new(G1).ScalarBaseMult(Order), prepared); !e.p.IsOne() {
+		t.Fatal("pairing with infinity isn't one")
+	}
+	inf := NewPreparedG2(new(G2).ScalarBaseMult(Order))
+	if e := PairPrepared(&G1{curveGen}, inf); !e.p.IsOne() {
+		t.Fatal("pairing with infinity isn't one")
+	}
+}
+
+func TestPairNegatedG2(t *testing.T) {
+	Ga := new(G2)
+	if _, err := Ga.Unmarshal((&G2{twistGen}).Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	Gb := new(G2).Neg(Ga)
+
+	e1 := Pair(&G1{curveGen}, Gb)
+	e2 := Pair(&G1{curveGen}, Ga)
+	e2.Neg(e2)
+
+	if *e1.p != *e2.p {
+		t.Fatal("e(g₁, -g₂) != e(g₁, g₂)⁻¹")
+	}
+}
+
 func TestTripartiteDiffieHellman(t *testing.T) {
 	a, _ := rand.Int(rand.Reader, Order)
 	b, _ := rand.Int(rand.Reader, Order)
@@ -168,7 +1322,7 @@ func TestSelfAddG1(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	Gb := &G1{curveGen}
+	Gb := &G1{&curvePoint{}}
 	Gb.p.Double(Ga.p)
 	mb := Gb.Marshal()
 
@@ -186,7 +1340,7 @@ func TestSelfAddG2(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	Gb := &G2{twistGen}
+	Gb := &G2{&twistPoint{}}
 	Gb.p.Double(Ga.p)
 	mb := Gb.Marshal()
 
@@ -198,6 +1352,58 @@ func TestSelfAddG2(t *testing.T) {
 	}
 }
 
+func TestConcurrentReads(t *testing.T) {
+	// Sums are not in affine form, which is what used to make Marshal and
+	// String write to their receivers. Run with -race to check that they no
+	// longer do.
+	_, g1, _ := RandomG1(rand.Reader)
+	_, g2, _ := RandomG2(rand.Reader)
+	g1.Add(g1, &G1{curveGen})
+	g2.Add(g2, &G2{twistGen})
+	gt := Pair(g1, g2)
+
+	p1, p2, pt := *g1.p, *g2.p, *gt.p
+	m1, m2, mt := g1.Marshal(), g2.Marshal(), gt.Marshal()
+	if *g1.p != p1 || *g2.p != p2 || *gt.p != pt {
+		t.Fatal("Marshal modified its receiver")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !bytes.Equal(g1.Marshal(), m1) || !bytes.Equal(g2.Marshal(), m2) || !bytes.Equal(gt.Marshal(), mt) {
+				errs <- "Marshal returned different bytes"
+				return
+			}
+			_, _, _ = g1.String(), g2.String(), gt.String()
+			_, _ = g1.MarshalCompressed(), g2.MarshalCompressed()
+			if !g1.p.IsOnCurve() || !g2.p.IsOnCurve() || !g2.IsInSubgroup() || !gt.IsInSubgroup() {
+				errs <- "element is not in its group"
+				return
+			}
+			if !bytes.Equal(Pair(g1, g2).Marshal(), mt) {
+				errs <- "Pair returned a different element"
+				return
+			}
+			new(G1).Add(g1, g1)
+			new(G2).ScalarMult(g2, big.NewInt(3))
+			new(GT).ScalarMult(gt, big.NewInt(3))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if *g1.p != p1 || *g2.p != p2 || *gt.p != pt {
+		t.Fatal("inputs were modified")
+	}
+}
+
 func TestDirtyUnmarshal(t *testing.T) {
 	_, Ga, err := RandomG2(rand.Reader)
 	if err != nil {
@@ -208,6 +1414,27 @@ func TestDirtyUnmarshal(t *testing.T) {
 	if _, err := Ga.Unmarshal(ma); err != nil {
 		t.Fatal(err)
 	}
+
+	// Invalid input leaves the receiver unchanged.
+	_, g1, _ := RandomG1(rand.Reader)
+	g2, gt := new(G2).Set(Ga), GeneratorGT()
+	a1, a2, at := new(G1).Set(g1), new(G2).Set(g2), new(GT).Set(gt)
+
+	bad1 := make([]byte, 64)
+	bad1[31], bad1[63] = 1, 1
+	if _, err := a1.Unmarshal(bad1); err == nil || !a1.Equal(g1) {
+		t.Errorf("G1: got err=%v, receiver changed: %v", err, !a1.Equal(g1))
+	}
+	bad2 := append([]byte{}, ma...)
+	bad2[len(bad2)-1] ^= 1
+	if _, err := a2.Unmarshal(bad2); err == nil || !a2.Equal(g2) {
+		t.Errorf("G2: got err=%v, receiver changed: %v", err, !a2.Equal(g2))
+	}
+	badt := gt.Marshal()
+	badt[len(badt)-1] ^= 1
+	if _, err := at.Unmarshal(badt); err == nil || !at.Equal(gt) {
+		t.Errorf("GT: got err=%v, receiver changed: %v", err, !at.Equal(gt))
+	}
 }
 
 func BenchmarkG1(b *testing.B) {
@@ -228,6 +1455,54 @@ func BenchmarkG2(b *testing.B) {
 	}
 }
 
+func BenchmarkScalarMultCT(b *testing.B) {
+	x, _ := rand.Int(rand.Reader, Order)
+	_, g1, _ := RandomG1(rand.Reader)
+	_, g2, _ := RandomG2(rand.Reader)
+	_, gt, _ := RandomGT(rand.Reader)
+	b.ResetTimer()
+
+	b.Run("G1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			new(G1).ScalarMultCT(g1, x)
+		}
+	})
+	b.Run("G2", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			new(G2).ScalarMultCT(g2, x)
+		}
+	})
+	b.Run("GT", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			new(GT).ScalarMultCT(gt, x)
+		}
+	})
+}
+
+func benchmarkMultiScalarMult(b *testing.B, n int) {
+	g1s, g2s := make([]*G1, n), make([]*G2, n)
+	scalars := make([]*big.Int, n)
+	for i := 0; i < n; i++ {
+		scalars[i], g1s[i], _ = RandomG1(rand.Reader)
+		g2s[i] = new(G2).ScalarBaseMult(scalars[i])
+	}
+	b.ResetTimer()
+
+	b.Run("G1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			new(G1).MultiScalarMult(g1s, scalars)
+		}
+	})
+	b.Run("G2", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			new(G2).MultiScalarMult(g2s, scalars)
+		}
+	})
+}
+
+func BenchmarkMultiScalarMult64(b *testing.B)   { benchmarkMultiScalarMult(b, 64) }
+func BenchmarkMultiScalarMult1024(b *testing.B) { benchmarkMultiScalarMult(b, 1024) }
+
 func BenchmarkGT(b *testing.B) {
 	x, _ := rand.Int(rand.Reader, Order)
 	b.ResetTimer()
@@ -237,8 +1512,37 @@ func BenchmarkGT(b *testing.B) {
 	}
 }
 
+func BenchmarkGTScalarMult(b *testing.B) {
+	x, _ := rand.Int(rand.Reader, Order)
+	_, Ga, _ := RandomGT(rand.Reader)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		new(GT).ScalarMult(Ga, x)
+	}
+}
+
 func BenchmarkPairing(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		Pair(&G1{curveGen}, &G2{twistGen})
 	}
 }
+
+func BenchmarkPairPrepared(b *testing.B) {
+	prepared := NewPreparedG2(&G2{twistGen})
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		PairPrepared(&G1{curveGen}, prepared)
+	}
+}
+
+func BenchmarkMultiPair(b *testing.B) {
+	g1s := []*G1{{curveGen}, {curveGen}, {curveGen}, {curveGen}}
+	g2s := []*G2{{twistGen}, {twistGen}, {twistGen}, {twistGen}}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		MultiPair(g1s, g2s)
+	}
+}
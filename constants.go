@@ -12,6 +12,9 @@ func bigFromBase10(s string) *big.Int {
 // u is the BN parameter that determines the prime: 1868033³.
 var u = bigFromBase10("6518589491078791937")
 
+// sixuSquared is 6u², which is p mod Order.
+var sixuSquared = bigFromBase10("254952053719217181996082057820017271814")
+
 // p is a prime over which we form a basic field: 36u⁴+36u³+24u²+6u+1.
 var p = bigFromBase10("65000549695646603732796438742359905742825358107623003571877145026864184071783")
 
@@ -46,9 +49,27 @@ var p2 = [4]uint64{0x185cac6c5e089667, 0xee5b88d120b5b59e, 0xaa6fecb86184dc21, 0
 // np is the negative inverse of p, mod 2^256.
 var np = [4]uint64{0x2387f9007f17daa9, 0x734b3343ab8513c8, 0x2524282f48054c12, 0x38997ae661c3ef3c}
 
+// order2 is Order, represented as little-endian 64-bit words.
+var order2 = [4]uint64{0x1a2ef45b57ac7261, 0x2e8d8e12f82b3924, 0xaa6fecb86184dc21, 0x8fb501e34aa387f9}
+
+// orderNp is the negative inverse of Order, mod 2^64.
+var orderNp = uint64(0x056417b72d284e5f)
+
+// orderR2 is R^2 mod Order where R = 2^256.
+var orderR2 = [4]uint64{0xb5f030132affbc35, 0x85a1f7da0792e95d, 0x26841e5fa6ee4895, 0x3d8f6c73765aefd5}
+
+// orderR3 is R^3 mod Order where R = 2^256.
+var orderR3 = [4]uint64{0xdc4acaf79cd08b26, 0xe8eb619d8fbf32ad, 0xcef9c4a175cd7899, 0x3f51764dd6d91833}
+
+// orderMinus2 is Order-2.
+var orderMinus2 = [4]uint64{0x1a2ef45b57ac725f, 0x2e8d8e12f82b3924, 0xaa6fecb86184dc21, 0x8fb501e34aa387f9}
+
 // rN1 is R^-1 where R = 2^256 mod p.
 var rN1 = &gfP{0xcbb781e36236117d, 0xcc65f3bcec8c91b, 0x2eab68888ea1f515, 0x1fc5c0956f92f825}
 
+// r1 is R where R = 2^256 mod p, which is the Montgomery encoding of 1.
+var r1 = &gfP{0xe7a35393a1f76999, 0x11a4772edf4a4a61, 0x559013479e7b23de, 0x704afe1cb55c7806}
+
 // r2 is R^2 where R = 2^256 mod p.
 var r2 = &gfP{0x9c21c3ff7e444f56, 0x409ed151b2efb0c2, 0xc6dc37b80fb1651, 0x7c36e0e62c2380b7}
 
@@ -64,8 +85,27 @@ var pMinus2 = [4]uint64{0x185cac6c5e089665, 0xee5b88d120b5b59e, 0xaa6fecb86184dc
 // pMinus1Over2 is (p-1)/2.
 var pMinus1Over2 = [4]uint64{0x0c2e56362f044b33, 0xf72dc468905adacf, 0xd537f65c30c26e10, 0x47da80f1a551c3fc}
 
+// twoInv is the Montgomery encoding of 1/2.
+var twoInv = &gfP{0x0, 0x0, 0x0, 0x8000000000000000}
+
 // s is the Montgomery encoding of the square root of -3. Then, s = sqrt(-3) * 2^256 mod p.
 var s = &gfP{0x236e675956be783b, 0x053957e6f379ab64, 0xe60789a768f4a5c4, 0x04f8979dd8bad754}
 
 // sMinus1Over2 is the Montgomery encoding of (s-1)/2. Then, sMinus1Over2 = ( (s-1) / 2) * 2^256 mod p.
 var sMinus1Over2 = &gfP{0x3642364f386c1db8, 0xe825f92d2acd661f, 0xf2aba7e846c19d14, 0x5a0bcea3dc52b7a0}
+
+// svdwZ is the constant Z of the Shallue-van de Woestijne map of RFC 9380,
+// section 6.6.1, for G₁, found with the algorithm of appendix H.1.
+var svdwZ = newGFp(1)
+
+// svdwC1 is the Montgomery encoding of g(Z) = Z³+3.
+var svdwC1 = &gfP{0x557749096dc3e32f, 0x7b7f42481b0808ad, 0x56f086f5555dfb12, 0x120cf2c8f587482c}
+
+// svdwC2 is the Montgomery encoding of -Z/2.
+var svdwC2 = &gfP{0x185cac6c5e089667, 0xee5b88d120b5b59e, 0xaa6fecb86184dc21, 0x0fb501e34aa387f9}
+
+// svdwC3 is the Montgomery encoding of sqrt(-g(Z)·3Z²), with sgn0 equal to 0.
+var svdwC3 = &gfP{0x46dcceb2ad7cf076, 0x0a72afcde6f356c8, 0xcc0f134ed1e94b88, 0x09f12f3bb175aea9}
+
+// svdwC4 is the Montgomery encoding of -4g(Z)/3Z².
+var svdwC4 = &gfP{0xa6684b0a7658bcd3, 0x9f073070fcaaff61, 0x8bd9e37145078d5e, 0x77a3be2cadef27be}
@@ -0,0 +1,320 @@
+package bn256
+
+// This file implements scalar multiplication and exponentiation whose timing
+// does not depend on the scalar, for use with secret scalars. The scalar is
+// processed in fixed 4-bit windows, table entries are read with constant-time
+// selects and points are added with the complete formulas of "Complete
+// addition formulas for prime order elliptic curves", Renes, Costello and
+// Batina, algorithms 7 and 9, in homogeneous projective coordinates
+// (x/z, y/z). https://eprint.iacr.org/2015/1060.pdf
+
+import (
+	"crypto/subtle"
+)
+
+// curveB3 is 3·curveB.
+var curveB3 = newGFp(9)
+
+// twistB3 is 3·twistB.
+var twistB3 = (&gfP2{}).MulScalar(twistB, newGFp(3))
+
+// JacobianToProjective sets c to a, converted from Jacobian to projective
+// coordinates. The point at infinity is (0:1:0) in both.
+func (c *curvePoint) JacobianToProjective(a *curvePoint) {
+	if a.IsInfinity() {
+		c.SetInfinity()
+		return
+	}
+
+	// (x/z², y/z³) = (xz/z³, y/z³)
+	z3 := &gfP{}
+	gfpMul(z3, &a.z, &a.z)
+	gfpMul(z3, z3, &a.z)
+	gfpMul(&c.x, &a.x, &a.z)
+	c.y.Set(&a.y)
+	c.z.Set(z3)
+}
+
+// ProjectiveToJacobian sets c to a, converted from projective to Jacobian
+// coordinates.
+func (c *curvePoint) ProjectiveToJacobian(a *curvePoint) {
+	// (x/z, y/z) = (xz/z², yz²/z³)
+	z2 := &gfP{}
+	gfpMul(z2, &a.z, &a.z)
+	gfpMul(&c.x, &a.x, &a.z)
+	gfpMul(&c.y, &a.y, z2)
+	c.z.Set(&a.z)
+	c.t.Set(z2)
+}
+
+// AddProjective sets c to a+b, where all three are in projective coordinates.
+// It is correct for all inputs, including equal points and the point at
+// infinity.
+func (c *curvePoint) AddProjective(a, b *curvePoint) {
+	t0, t1, t2, t3, t4 := &gfP{}, &gfP{}, &gfP{}, &gfP{}, &gfP{}
+	x3, y3, z3 := &gfP{}, &gfP{}, &gfP{}
+
+	gfpMul(t0, &a.x, &b.x)
+	gfpMul(t1, &a.y, &b.y)
+	gfpMul(t2, &a.z, &b.z)
+	gfpAdd(t3, &a.x, &a.y)
+	gfpAdd(t4, &b.x, &b.y)
+	gfpMul(t3, t3, t4)
+	gfpAdd(t4, t0, t1)
+	gfpSub(t3, t3, t4)
+	gfpAdd(t4, &a.y, &a.z)
+	gfpAdd(x3, &b.y, &b.z)
+	gfpMul(t4, t4, x3)
+	gfpAdd(x3, t1, t2)
+	gfpSub(t4, t4, x3)
+	gfpAdd(x3, &a.x, &a.z)
+	gfpAdd(y3, &b.x, &b.z)
+	gfpMul(x3, x3, y3)
+	gfpAdd(y3, t0, t2)
+	gfpSub(y3, x3, y3)
+	gfpAdd(x3, t0, t0)
+	gfpAdd(t0, x3, t0)
+	gfpMul(t2, curveB3, t2)
+	gfpAdd(z3, t1, t2)
+	gfpSub(t1, t1, t2)
+	gfpMul(y3, curveB3, y3)
+	gfpMul(x3, t4, y3)
+	gfpMul(t2, t3, t1)
+	gfpSub(x3, t2, x3)
+	gfpMul(y3, y3, t0)
+	gfpMul(t1, t1, z3)
+	gfpAdd(y3, t1, y3)
+	gfpMul(t0, t0, t3)
+	gfpMul(z3, z3, t4)
+	gfpAdd(z3, z3, t0)
+
+	c.x.Set(x3)
+	c.y.Set(y3)
+	c.z.Set(z3)
+}
+
+// DoubleProjective sets c to 2a, where both are in projective coordinates.
+func (c *curvePoint) DoubleProjective(a *curvePoint) {
+	t0, t1, t2 := &gfP{}, &gfP{}, &gfP{}
+	x3, y3, z3 := &gfP{}, &gfP{}, &gfP{}
+
+	gfpMul(t0, &a.y, &a.y)
+	gfpAdd(z3, t0, t0)
+	gfpAdd(z3, z3, z3)
+	gfpAdd(z3, z3, z3)
+	gfpMul(t1, &a.y, &a.z)
+	gfpMul(t2, &a.z, &a.z)
+	gfpMul(t2, curveB3, t2)
+	gfpMul(x3, t2, z3)
+	gfpAdd(y3, t0, t2)
+	gfpMul(z3, t1, z3)
+	gfpAdd(t1, t2, t2)
+	gfpAdd(t2, t1, t2)
+	gfpSub(t0, t0, t2)
+	gfpMul(y3, t0, y3)
+	gfpAdd(y3, x3, y3)
+	gfpMul(t1, &a.x, &a.y)
+	gfpMul(x3, t0, t1)
+	gfpAdd(x3, x3, x3)
+
+	c.x.Set(x3)
+	c.y.Set(y3)
+	c.z.Set(z3)
+}
+
+// Lookup sets c to table[idx] without revealing idx through timing.
+func (c *curvePoint) Lookup(table []curvePoint, idx uint) {
+	for i := range table {
+		c.Cmov(&table[i], subtle.ConstantTimeEq(int32(i), int32(idx)))
+	}
+}
+
+// MulCT sets c to a*k, where k is a scalar in little-endian 64-bit words, in
+// constant time with respect to k.
+func (c *curvePoint) MulCT(a *curvePoint, k *[4]uint64) {
+	table := [1 << baseWindow]curvePoint{}
+	table[0].SetInfinity()
+	table[1].JacobianToProjective(a)
+	for i := 2; i < len(table); i++ {
+		table[i].AddProjective(&table[i-1], &table[1])
+	}
+
+	sum, t := &curvePoint{}, &curvePoint{}
+	sum.SetInfinity()
+	for i := baseWindows - 1; i >= 0; i-- {
+		for j := 0; j < baseWindow; j++ {
+			sum.DoubleProjective(sum)
+		}
+		t.Lookup(table[:], scalarWindow(k, uint(i)*baseWindow, baseWindow))
+		sum.AddProjective(sum, t)
+	}
+
+	c.ProjectiveToJacobian(sum)
+}
+
+// JacobianToProjective sets c to a, converted from Jacobian to projective
+// coordinates. The point at infinity is (0:1:0) in both.
+func (c *twistPoint) JacobianToProjective(a *twistPoint) {
+	if a.IsInfinity() {
+		c.SetInfinity()
+		return
+	}
+
+	z3 := (&gfP2{}).Square(&a.z)
+	z3.Mul(z3, &a.z)
+	c.x.Mul(&a.x, &a.z)
+	c.y.Set(&a.y)
+	c.z.Set(z3)
+}
+
+// ProjectiveToJacobian sets c to a, converted from projective to Jacobian
+// coordinates.
+func (c *twistPoint) ProjectiveToJacobian(a *twistPoint) {
+	z2 := (&gfP2{}).Square(&a.z)
+	c.x.Mul(&a.x, &a.z)
+	c.y.Mul(&a.y, z2)
+	c.z.Set(&a.z)
+	c.t.Set(z2)
+}
+
+// AddProjective sets c to a+b, where all three are in projective coordinates.
+// It is correct for all inputs in G₂, including equal points and the point at
+// infinity.
+func (c *twistPoint) AddProjective(a, b *twistPoint) {
+	// For additional comments, see the same function for curvePoint.
+
+	t0 := (&gfP2{}).Mul(&a.x, &b.x)
+	t1 := (&gfP2{}).Mul(&a.y, &b.y)
+	t2 := (&gfP2{}).Mul(&a.z, &b.z)
+	t3 := (&gfP2{}).Add(&a.x, &a.y)
+	t4 := (&gfP2{}).Add(&b.x, &b.y)
+	t3.Mul(t3, t4)
+	t4.Add(t0, t1)
+	t3.Sub(t3, t4)
+	t4.Add(&a.y, &a.z)
+	x3 := (&gfP2{}).Add(&b.y, &b.z)
+	t4.Mul(t4, x3)
+	x3.Add(t1, t2)
+	t4.Sub(t4, x3)
+	x3.Add(&a.x, &a.z)
+	y3 := (&gfP2{}).Add(&b.x, &b.z)
+	x3.Mul(x3, y3)
+	y3.Add(t0, t2)
+	y3.Sub(x3, y3)
+	x3.Add(t0, t0)
+	t0.Add(x3, t0)
+	t2.Mul(twistB3, t2)
+	z3 := (&gfP2{}).Add(t1, t2)
+	t1.Sub(t1, t2)
+	y3.Mul(twistB3, y3)
+	x3.Mul(t4, y3)
+	t2.Mul(t3, t1)
+	x3.Sub(t2, x3)
+	y3.Mul(y3, t0)
+	t1.Mul(t1, z3)
+	y3.Add(t1, y3)
+	t0.Mul(t0, t3)
+	z3.Mul(z3, t4)
+	z3.Add(z3, t0)
+
+	c.x.Set(x3)
+	c.y.Set(y3)
+	c.z.Set(z3)
+}
+
+// DoubleProjective sets c to 2a, where both are in projective coordinates.
+func (c *twistPoint) DoubleProjective(a *twistPoint) {
+	t0 := (&gfP2{}).Square(&a.y)
+	z3 := (&gfP2{}).Add(t0, t0)
+	z3.Add(z3, z3).Add(z3, z3)
+	t1 := (&gfP2{}).Mul(&a.y, &a.z)
+	t2 := (&gfP2{}).Square(&a.z)
+	t2.Mul(twistB3, t2)
+	x3 := (&gfP2{}).Mul(t2, z3)
+	y3 := (&gfP2{}).Add(t0, t2)
+	z3.Mul(t1, z3)
+	t1.Add(t2, t2)
+	t2.Add(t1, t2)
+	t0.Sub(t0, t2)
+	y3.Mul(t0, y3)
+	y3.Add(x3, y3)
+	t1.Mul(&a.x, &a.y)
+	x3.Mul(t0, t1)
+	x3.Add(x3, x3)
+
+	c.x.Set(x3)
+	c.y.Set(y3)
+	c.z.Set(z3)
+}
+
+// Lookup sets c to table[idx] without revealing idx through timing.
+func (c *twistPoint) Lookup(table []twistPoint, idx uint) {
+	for i := range table {
+		c.Cmov(&table[i], subtle.ConstantTimeEq(int32(i), int32(idx)))
+	}
+}
+
+// MulCT sets c to a*k, where k is a scalar in little-endian 64-bit words, in
+// constant time with respect to k. a must be in G₂.
+func (c *twistPoint) MulCT(a *twistPoint, k *[4]uint64) {
+	table := [1 << baseWindow]twistPoint{}
+	table[0].SetInfinity()
+	table[1].JacobianToProjective(a)
+	for i := 2; i < len(table); i++ {
+		table[i].AddProjective(&table[i-1], &table[1])
+	}
+
+	sum, t := &twistPoint{}, &twistPoint{}
+	sum.SetInfinity()
+	for i := baseWindows - 1; i >= 0; i-- {
+		for j := 0; j < baseWindow; j++ {
+			sum.DoubleProjective(sum)
+		}
+		t.Lookup(table[:], scalarWindow(k, uint(i)*baseWindow, baseWindow))
+		sum.AddProjective(sum, t)
+	}
+
+	c.ProjectiveToJacobian(sum)
+}
+
+// Lookup sets e to table[idx] without revealing idx through timing, and then
+// returns e.
+func (e *gfP12) Lookup(table []gfP12, idx uint) *gfP12 {
+	for i := range table {
+		e.Cmov(&table[i], subtle.ConstantTimeEq(int32(i), int32(idx)))
+	}
+	return e
+}
+
+// ExpCT sets e to a^k, where k is a scalar in little-endian 64-bit words, in
+// constant time with respect to k, and then returns e. a must be in the
+// cyclotomic subgroup, for example in GT.
+func (e *gfP12) ExpCT(a *gfP12, k *[4]uint64) *gfP12 {
+	return e.expCT(a, k[:], (*gfP12).CyclotomicSquare)
+}
+
+// expCT sets e to a^k, where k is little-endian 64-bit words whose number is a
+// multiple of four, in constant time with respect to the value of k, and then
+// returns e. square must square any power of a.
+func (e *gfP12) expCT(a *gfP12, k []uint64, square func(e, a *gfP12) *gfP12) *gfP12 {
+	table := [1 << baseWindow]gfP12{}
+	table[0].SetOne()
+	table[1].Set(a)
+	for i := 2; i < len(table); i++ {
+		table[i].Mul(&table[i-1], a)
+	}
+
+	sum, t := (&gfP12{}).SetOne(), &gfP12{}
+	for w := len(k) - 4; w >= 0; w -= 4 {
+		kw := (*[4]uint64)(k[w:])
+		for i := baseWindows - 1; i >= 0; i-- {
+			for j := 0; j < baseWindow; j++ {
+				square(sum, sum)
+			}
+			t.Lookup(table[:], scalarWindow(kw, uint(i)*baseWindow, baseWindow))
+			sum.Mul(sum, t)
+		}
+	}
+
+	return e.Set(sum)
+}
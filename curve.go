@@ -21,10 +21,12 @@ var curveGen = &curvePoint{
 }
 
 func (c *curvePoint) String() string {
-	c.MakeAffine()
+	a := &curvePoint{}
+	a.Set(c)
+	a.MakeAffine()
 	x, y := &gfP{}, &gfP{}
-	montDecode(x, &c.x)
-	montDecode(y, &c.y)
+	montDecode(x, &a.x)
+	montDecode(y, &a.y)
 	return "(" + x.String() + ", " + y.String() + ")"
 }
 
@@ -35,17 +37,28 @@ func (c *curvePoint) Set(a *curvePoint) {
 	c.t.Set(&a.t)
 }
 
-// IsOnCurve returns true iff c is on the curve.
+// Cmov sets c to a if cond is 1 and leaves it unchanged if cond is 0, in
+// constant time.
+func (c *curvePoint) Cmov(a *curvePoint, cond int) {
+	c.x.Cmov(&a.x, cond)
+	c.y.Cmov(&a.y, cond)
+	c.z.Cmov(&a.z, cond)
+	c.t.Cmov(&a.t, cond)
+}
+
+// IsOnCurve returns true iff c is on the curve. It doesn't modify c.
 func (c *curvePoint) IsOnCurve() bool {
-	c.MakeAffine()
-	if c.IsInfinity() {
+	a := &curvePoint{}
+	a.Set(c)
+	a.MakeAffine()
+	if a.IsInfinity() {
 		return true
 	}
 
 	y2, x3 := &gfP{}, &gfP{}
-	gfpMul(y2, &c.y, &c.y)
-	gfpMul(x3, &c.x, &c.x)
-	gfpMul(x3, x3, &c.x)
+	gfpMul(y2, &a.y, &a.y)
+	gfpMul(x3, &a.x, &a.x)
+	gfpMul(x3, x3, &a.x)
 	gfpAdd(x3, x3, curveB)
 
 	return *y2 == *x3
@@ -53,7 +66,7 @@ func (c *curvePoint) IsOnCurve() bool {
 
 func (c *curvePoint) SetInfinity() {
 	c.x = gfP{0}
-	c.y = *newGFp(1)
+	c.y = *r1
 	c.z = gfP{0}
 	c.t = gfP{0}
 }
@@ -62,6 +75,33 @@ func (c *curvePoint) IsInfinity() bool {
 	return c.z == gfP{0}
 }
 
+// Equal returns true iff c and a are the same point. It compares their Jacobian
+// coordinates without converting either to affine form.
+func (c *curvePoint) Equal(a *curvePoint) bool {
+	if c.IsInfinity() || a.IsInfinity() {
+		return c.IsInfinity() && a.IsInfinity()
+	}
+
+	// (x1/z1², y1/z1³) = (x2/z2², y2/z2³) iff x1·z2² = x2·z1² and
+	// y1·z2³ = y2·z1³.
+	z12, z22 := &gfP{}, &gfP{}
+	gfpMul(z12, &c.z, &c.z)
+	gfpMul(z22, &a.z, &a.z)
+
+	u1, u2 := &gfP{}, &gfP{}
+	gfpMul(u1, &c.x, z22)
+	gfpMul(u2, &a.x, z12)
+	if *u1 != *u2 {
+		return false
+	}
+
+	gfpMul(z12, z12, &c.z)
+	gfpMul(z22, z22, &a.z)
+	gfpMul(u1, &c.y, z22)
+	gfpMul(u2, &a.y, z12)
+	return *u1 == *u2
+}
+
 func (c *curvePoint) Add(a, b *curvePoint) {
 	if a.IsInfinity() {
 		c.Set(b)
@@ -149,6 +189,76 @@ func (c *curvePoint) Add(a, b *curvePoint) {
 	gfpMul(&c.z, t4, h)
 }
 
+// AddMixed sets c to a+b, where b must be in affine form (z=1) unless it is
+// the point at infinity.
+func (c *curvePoint) AddMixed(a, b *curvePoint) {
+	if a.IsInfinity() {
+		c.Set(b)
+		return
+	}
+	if b.IsInfinity() {
+		c.Set(a)
+		return
+	}
+
+	// See http://hyperelliptic.org/EFD/g1p/auto-code/shortw/jacobian-0/addition/madd-2007-bl.op3
+	z12 := &gfP{}
+	gfpMul(z12, &a.z, &a.z)
+
+	u2, s2 := &gfP{}, &gfP{}
+	gfpMul(u2, &b.x, z12)
+	gfpMul(s2, &b.y, &a.z)
+	gfpMul(s2, s2, z12)
+
+	h, r := &gfP{}, &gfP{}
+	gfpSub(h, u2, &a.x)
+	gfpSub(r, s2, &a.y)
+	if *h == (gfP{0}) {
+		if *r == (gfP{0}) {
+			c.Double(a)
+		} else {
+			c.SetInfinity()
+		}
+		return
+	}
+	gfpAdd(r, r, r)
+
+	// i = 4h², j = h·i and v = x1·i
+	hh, i, j, v := &gfP{}, &gfP{}, &gfP{}, &gfP{}
+	gfpMul(hh, h, h)
+	gfpAdd(i, hh, hh)
+	gfpAdd(i, i, i)
+	gfpMul(j, h, i)
+	gfpMul(v, &a.x, i)
+
+	// x3 = r²-j-2v
+	x3, t := &gfP{}, &gfP{}
+	gfpMul(x3, r, r)
+	gfpSub(x3, x3, j)
+	gfpAdd(t, v, v)
+	gfpSub(x3, x3, t)
+
+	// y3 = r(v-x3) - 2·y1·j
+	y3 := &gfP{}
+	gfpSub(t, v, x3)
+	gfpMul(y3, r, t)
+	gfpMul(t, &a.y, j)
+	gfpAdd(t, t, t)
+	gfpSub(y3, y3, t)
+
+	// z3 = (z1+h)²-z1²-h²
+	z3 := &gfP{}
+	gfpAdd(t, &a.z, h)
+	gfpMul(z3, t, t)
+	gfpSub(z3, z3, z12)
+	gfpSub(z3, z3, hh)
+
+	c.x.Set(x3)
+	c.y.Set(y3)
+	c.z.Set(z3)
+	gfpMul(&c.t, z3, z3)
+}
+
 func (c *curvePoint) Double(a *curvePoint) {
 	// See http://hyperelliptic.org/EFD/g1p/auto-code/shortw/jacobian-0/doubling/dbl-2009-l.op3
 	A, B, C := &gfP{}, &gfP{}, &gfP{}
@@ -182,14 +292,39 @@ func (c *curvePoint) Double(a *curvePoint) {
 	gfpSub(&c.y, t2, t)
 }
 
+// Mul sets c to a*scalar. a must be in G₁, because the scalar is reduced
+// modulo Order and split into two halves with the GLV method.
 func (c *curvePoint) Mul(a *curvePoint, scalar *big.Int) {
+	ks := curveLattice.decompose(scalar)
+
+	// The table holds combinations of a and φ(a) = (βx, y) where bit i of
+	// the index selects the i-th point. Negative halves are handled by
+	// negating the corresponding point.
+	table := [4]curvePoint{}
+	table[0].SetInfinity()
+	table[1].Set(a)
+	table[2].Set(a)
+	gfpMul(&table[2].x, &table[2].x, xiTo2PSquaredMinus2Over3)
+	for i, k := range ks {
+		if k.Sign() < 0 {
+			table[1<<uint(i)].Neg(&table[1<<uint(i)])
+			ks[i] = new(big.Int).Neg(k)
+		}
+	}
+	table[3].Add(&table[1], &table[2])
+
+	n := ks[0].BitLen()
+	if m := ks[1].BitLen(); m > n {
+		n = m
+	}
+
 	sum, t := &curvePoint{}, &curvePoint{}
 	sum.SetInfinity()
 
-	for i := scalar.BitLen(); i >= 0; i-- {
+	for i := n - 1; i >= 0; i-- {
 		t.Double(sum)
-		if scalar.Bit(i) != 0 {
-			sum.Add(t, a)
+		if idx := ks[0].Bit(i) | ks[1].Bit(i)<<1; idx != 0 {
+			sum.Add(t, &table[idx])
 		} else {
 			sum.Set(t)
 		}
@@ -199,11 +334,11 @@ func (c *curvePoint) Mul(a *curvePoint, scalar *big.Int) {
 }
 
 func (c *curvePoint) MakeAffine() {
-	if c.z == *newGFp(1) {
+	if c.z == *r1 {
 		return
-	} else if c.z == *newGFp(0) {
+	} else if c.z == (gfP{0}) {
 		c.x = gfP{0}
-		c.y = *newGFp(1)
+		c.y = *r1
 		c.t = gfP{0}
 		return
 	}
@@ -218,13 +353,13 @@ func (c *curvePoint) MakeAffine() {
 	gfpMul(&c.x, &c.x, zInv2)
 	gfpMul(&c.y, t, zInv2)
 
-	c.z = *newGFp(1)
-	c.t = *newGFp(1)
+	c.z = *r1
+	c.t = *r1
 }
 
 func (c *curvePoint) Neg(a *curvePoint) {
 	c.x.Set(&a.x)
 	gfpNeg(&c.y, &a.y)
 	c.z.Set(&a.z)
-	c.t = gfP{0}
+	c.t.Set(&a.t)
 }
@@ -0,0 +1,191 @@
+package bn256
+
+// This file implements an inverse of mapToCurve and, on top of it, the
+// Elligator Squared encoding of "Elligator Squared: Uniform Points on Elliptic
+// Curves of Prime Order as Uniform Random Strings", Tibouchi, section 4, which
+// represents any point of G₁ as 64 bytes that are indistinguishable from
+// uniformly random bytes. https://eprint.iacr.org/2014/043.pdf
+
+import (
+	"io"
+	"math/bits"
+)
+
+// mapToCurveBranches is the number of branches of mapToCurveInverse, and the
+// largest number of preimages of a point under mapToCurve.
+const mapToCurveBranches = 4
+
+// mapToCurveInverse returns a t such that mapToCurve(t) is the affine point
+// (x, y), found with the given branch of the inverse map, and true, or false
+// if that branch finds no such t. Branches 0 and 1 invert x1 and x2, and
+// branches 2 and 3 invert x3 with each of the two roots of a quadratic.
+func mapToCurveInverse(x, y *gfP, branch int) (*gfP, bool) {
+	one := newGFp(1)
+	tt := &gfP{} // t²
+
+	switch branch {
+	case 0, 1:
+		x1 := &gfP{}
+		x1.Set(x)
+		if branch == 1 {
+			// x1 = -1 - x2
+			gfpAdd(x1, x, one)
+			gfpNeg(x1, x1)
+		}
+
+		// x1 = (s-1)/2 - s·t²/(4+t²), so t² = 4d/(s-d) where
+		// d = (s-1)/2 - x1.
+		d, den := &gfP{}, &gfP{}
+		gfpSub(d, sMinus1Over2, x1)
+		gfpSub(den, s, d)
+		if *den == (gfP{}) {
+			return nil, false
+		}
+		den.Invert(den)
+		gfpMul(tt, d, den)
+		gfpMul(tt, tt, newGFp(4))
+
+	case 2, 3:
+		// x3 = 1 - (4+t²)²/3t², so t² is a root of v² + (3x3+5)v + 16.
+		b := &gfP{}
+		gfpMul(b, x, newGFp(3))
+		gfpAdd(b, b, newGFp(5))
+
+		disc := &gfP{}
+		gfpMul(disc, b, b)
+		gfpSub(disc, disc, newGFp(64))
+		if legendre(disc) < 0 {
+			return nil, false
+		}
+		disc.Sqrt(disc)
+		if branch == 3 {
+			gfpNeg(disc, disc)
+		}
+		gfpSub(tt, disc, b)
+		gfpMul(tt, tt, twoInv)
+
+	default:
+		panic("bn256: invalid branch of mapToCurveInverse")
+	}
+
+	if legendre(tt) < 0 {
+		return nil, false
+	}
+	t := &gfP{}
+	t.Sqrt(tt)
+	if sign0(t) != sign0(y) {
+		gfpNeg(t, t)
+	}
+
+	// mapToCurve uses x1, x2 or x3 depending on which of them is on the curve,
+	// which the equations above ignore, so check that t maps to (x, y).
+	q := mapToCurve(t).p
+	if gfpEqual(&q.x, x)&gfpEqual(&q.y, y) == 0 {
+		return nil, false
+	}
+	return t, true
+}
+
+// mapToCurvePreimages returns all the t such that mapToCurve(t) is a, which are
+// at most mapToCurveBranches.
+func mapToCurvePreimages(a *curvePoint) []*gfP {
+	q := &curvePoint{}
+	q.Set(a)
+	q.MakeAffine()
+	if q.IsInfinity() {
+		return nil
+	}
+
+	ts := make([]*gfP, 0, mapToCurveBranches)
+	for branch := 0; branch < mapToCurveBranches; branch++ {
+		t, ok := mapToCurveInverse(&q.x, &q.y, branch)
+		if !ok {
+			continue
+		}
+
+		// Two branches only find the same t if their equations share a root.
+		dup := false
+		for _, t2 := range ts {
+			dup = dup || *t2 == *t
+		}
+		if !dup {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// MarshalUniform converts e to 64 bytes that are indistinguishable from
+// uniformly random bytes, reading randomness from r. Each point has many such
+// encodings, and any 64 bytes decode to a point with UnmarshalUniform.
+//
+// The number of attempts is random, and MarshalUniform is not constant time.
+func (e *G1) MarshalUniform(r io.Reader) ([]byte, error) {
+	a := e.point()
+
+	// Each attempt reads a field element u and a byte that holds the index of
+	// the preimage v and one bit for each of u and v.
+	var buf [h2cFieldBytes + 1]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, err
+		}
+		u := hashToField(buf[:h2cFieldBytes])
+		ctl := buf[h2cFieldBytes]
+
+		// Pick v uniformly among the preimages of e - f(u), by picking one of
+		// mapToCurveBranches slots and starting again if it is empty.
+		q := &curvePoint{}
+		q.Neg(mapToCurve(u).p)
+		q.Add(q, a)
+		vs := mapToCurvePreimages(q)
+		j := int(ctl % mapToCurveBranches)
+		if j >= len(vs) {
+			continue
+		}
+
+		out := make([]byte, 64)
+		if !liftUniform(out[:32], u, ctl>>2&1) || !liftUniform(out[32:], vs[j], ctl>>3&1) {
+			continue
+		}
+		return out, nil
+	}
+}
+
+// liftUniform writes the 32-byte big-endian encoding of e+bit·p to out, and
+// returns false if that doesn't fit in 32 bytes. Starting again on false makes
+// every 32-byte string equally likely, since 2^256 < 2p.
+func liftUniform(out []byte, e *gfP, bit byte) bool {
+	x := &gfP{}
+	montDecode(x, e)
+
+	mask := -uint64(bit)
+	var carry uint64
+	for w := 0; w < 4; w++ {
+		x[w], carry = bits.Add64(x[w], p2[w]&mask, carry)
+	}
+	if carry != 0 {
+		return false
+	}
+
+	x.Marshal(out)
+	return true
+}
+
+// UnmarshalUniform sets e to the point encoded by the first 64 bytes of m, as
+// output by MarshalUniform, and then returns the rest of m. Every 64-byte string
+// is a valid encoding.
+func (e *G1) UnmarshalUniform(m []byte) ([]byte, error) {
+	if len(m) < 64 {
+		return nil, newDecodeError("G1", len(m), ErrShortBuffer)
+	}
+	if e.p == nil {
+		e.p = &curvePoint{}
+	}
+
+	var u, v [h2cFieldBytes]byte
+	copy(u[h2cFieldBytes-32:], m[:32])
+	copy(v[h2cFieldBytes-32:], m[32:64])
+	e.p.Add(mapToCurve(gfpFromWide(&u)).p, mapToCurve(gfpFromWide(&v)).p)
+	return m[64:], nil
+}
@@ -0,0 +1,184 @@
+package bn256
+
+// This file implements the interfaces of the encoding, encoding/json and
+// encoding/gob packages for G1, G2 and GT. The binary form is the output of
+// Marshal, and the text form is that in lowercase hexadecimal.
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the output of
+// Marshal.
+func (e *G1) MarshalBinary() ([]byte, error) {
+	return e.Marshal(), nil
+}
+
+// AppendBinary implements encoding.BinaryAppender. It appends the output of
+// Marshal to b, and doesn't allocate if b has enough spare capacity.
+func (e *G1) AppendBinary(b []byte) ([]byte, error) {
+	return e.appendMarshal(b), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Unlike Unmarshal, it
+// returns an error if data holds more than one element.
+func (e *G1) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary("G1", data, e.Unmarshal)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e *G1) MarshalText() ([]byte, error) {
+	return hex.AppendEncode(nil, e.Marshal()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *G1) UnmarshalText(text []byte) error {
+	return unmarshalText("G1", text, e.UnmarshalBinary)
+}
+
+// MarshalJSON implements json.Marshaler. e is encoded as a string that holds
+// the output of MarshalText.
+func (e *G1) MarshalJSON() ([]byte, error) {
+	return marshalJSON(e.Marshal()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *G1) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON("G1", data, e.UnmarshalBinary)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the output of
+// Marshal.
+func (e *G2) MarshalBinary() ([]byte, error) {
+	return e.Marshal(), nil
+}
+
+// AppendBinary implements encoding.BinaryAppender. It appends the output of
+// Marshal to b, and doesn't allocate if b has enough spare capacity.
+func (e *G2) AppendBinary(b []byte) ([]byte, error) {
+	return e.appendMarshal(b), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Unlike Unmarshal, it
+// returns an error if data holds more than one element.
+func (e *G2) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary("G2", data, e.Unmarshal)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e *G2) MarshalText() ([]byte, error) {
+	return hex.AppendEncode(nil, e.Marshal()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *G2) UnmarshalText(text []byte) error {
+	return unmarshalText("G2", text, e.UnmarshalBinary)
+}
+
+// MarshalJSON implements json.Marshaler. e is encoded as a string that holds
+// the output of MarshalText.
+func (e *G2) MarshalJSON() ([]byte, error) {
+	return marshalJSON(e.Marshal()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *G2) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON("G2", data, e.UnmarshalBinary)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the output of
+// Marshal.
+func (e *GT) MarshalBinary() ([]byte, error) {
+	return e.Marshal(), nil
+}
+
+// AppendBinary implements encoding.BinaryAppender. It appends the output of
+// Marshal to b, and doesn't allocate if b has enough spare capacity.
+func (e *GT) AppendBinary(b []byte) ([]byte, error) {
+	return e.appendMarshal(b), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Unlike Unmarshal, it
+// returns an error if data holds more than one element.
+func (e *GT) UnmarshalBinary(data []byte) error {
+	return unmarshalBinary("GT", data, e.Unmarshal)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e *GT) MarshalText() ([]byte, error) {
+	return hex.AppendEncode(nil, e.Marshal()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *GT) UnmarshalText(text []byte) error {
+	return unmarshalText("GT", text, e.UnmarshalBinary)
+}
+
+// MarshalJSON implements json.Marshaler. e is encoded as a string that holds
+// the output of MarshalText.
+func (e *GT) MarshalJSON() ([]byte, error) {
+	return marshalJSON(e.Marshal()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *GT) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON("GT", data, e.UnmarshalBinary)
+}
+
+// unmarshalBinary decodes data with unmarshal, which is the Unmarshal method
+// of group, and returns ErrNonCanonical if any of data is left over.
+func unmarshalBinary(group string, data []byte, unmarshal func([]byte) ([]byte, error)) error {
+	rest, err := unmarshal(data)
+	if err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return newDecodeError(group, len(data)-len(rest), ErrNonCanonical)
+	}
+	return nil
+}
+
+// unmarshalText decodes the hexadecimal in text and then decodes the result
+// with unmarshal, which is the UnmarshalBinary method of group. The offsets of
+// errors are those in text. Only lowercase hexadecimal, as output by
+// MarshalText, is accepted, so that every element has one text encoding.
+func unmarshalText(group string, text []byte, unmarshal func([]byte) error) error {
+	for i, c := range text {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return newDecodeError(group, i, ErrNonCanonical)
+		}
+	}
+	data := make([]byte, hex.DecodedLen(len(text)))
+	if n, err := hex.Decode(data, text); err != nil {
+		return newDecodeError(group, 2*n, ErrNonCanonical)
+	}
+
+	err := unmarshal(data)
+	var de *DecodeError
+	if errors.As(err, &de) {
+		de.Offset *= 2
+	}
+	return err
+}
+
+// marshalJSON returns the hexadecimal encoding of data as a JSON string.
+func marshalJSON(data []byte) []byte {
+	out := make([]byte, 0, 2+hex.EncodedLen(len(data)))
+	out = append(out, '"')
+	out = hex.AppendEncode(out, data)
+	return append(out, '"')
+}
+
+// unmarshalJSON decodes a JSON string with unmarshalText. As is conventional,
+// null leaves the receiver unchanged.
+func unmarshalJSON(group string, data []byte, unmarshal func([]byte) error) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	return unmarshalText(group, []byte(text), unmarshal)
+}
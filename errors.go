@@ -0,0 +1,44 @@
+package bn256
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// These errors are returned, wrapped in a *DecodeError, when decoding fails.
+// Use errors.Is to test for them.
+var (
+	// ErrShortBuffer means that the input ended before the encoding did.
+	ErrShortBuffer = errors.New("bn256: not enough data")
+	// ErrNotOnCurve means that the coordinates are not those of a point on
+	// the curve.
+	ErrNotOnCurve = errors.New("bn256: point not on curve")
+	// ErrNotInSubgroup means that the point or element is not in the group
+	// of order Order.
+	ErrNotInSubgroup = errors.New("bn256: not in subgroup")
+	// ErrNonCanonical means that the input is not the encoding that Marshal
+	// or MarshalCompressed would output, for example because a coordinate
+	// isn't reduced modulo p.
+	ErrNonCanonical = errors.New("bn256: non-canonical encoding")
+	// ErrInvalidFlag means that the first byte of the input is not one of
+	// the flags of the encoding.
+	ErrInvalidFlag = errors.New("bn256: invalid flag byte")
+)
+
+// DecodeError records a failure to decode an element of a group, or a Scalar.
+type DecodeError struct {
+	Group  string // "G1", "G2", "GT" or "Scalar"
+	Offset int    // offset in the input of the value that failed to decode
+	Err    error  // one of the sentinel errors above
+}
+
+func (e *DecodeError) Error() string {
+	return "bn256: decoding " + e.Group + " at offset " + strconv.Itoa(e.Offset) + ": " + strings.TrimPrefix(e.Err.Error(), "bn256: ")
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+func newDecodeError(group string, offset int, err error) error {
+	return &DecodeError{group, offset, err}
+}
@@ -25,15 +25,16 @@ func ExamplePair() {
 	qc := new(G2).ScalarBaseMult(c)
 
 	// Now each party exchanges its public values with the other two and
-	// all parties can calculate the shared key.
+	// all parties can calculate the shared key. ScalarMultCT is used because
+	// the private values are secret.
 	k1 := Pair(pb, qc)
-	k1.ScalarMult(k1, a)
+	k1.ScalarMultCT(k1, a)
 
 	k2 := Pair(pc, qa)
-	k2.ScalarMult(k2, b)
+	k2.ScalarMultCT(k2, b)
 
 	k3 := Pair(pa, qb)
-	k3.ScalarMult(k3, c)
+	k3.ScalarMultCT(k3, c)
 
 	// k1, k2 and k3 will all be equal.
 }
@@ -4,7 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
-	"math/big"
+	"math/bits"
 
 	"golang.org/x/crypto/hkdf"
 )
@@ -23,29 +23,40 @@ func newGFp(x int64) (out *gfP) {
 	return out
 }
 
-// hashToBase implements hashing a message to an element of the field.
+// hashToBase implements hashing a message to an element of the field. i
+// separates the elements derived from the same message: HashG1 uses 1, and
+// HashG2 uses 3 and 4 for the real and imaginary parts, so that it shares no
+// element with HashG1.
 //
-// L = ceil((256+128)/8)=48, ctr = 0, i = 1
-func hashToBase(msg, dst []byte) *gfP {
+// L = ceil((256+128)/8)=48, ctr = 0
+func hashToBase(msg, dst []byte, i byte) *gfP {
 	var t [48]byte
-	info := []byte{'H', '2', 'C', byte(0), byte(1)}
+	info := []byte{'H', '2', 'C', byte(0), i}
 	r := hkdf.New(sha256.New, msg, dst, info)
 	if _, err := r.Read(t[:]); err != nil {
 		panic(err)
 	}
-	var x big.Int
-	v := x.SetBytes(t[:]).Mod(&x, p).Bytes()
-	v32 := [32]byte{}
-	for i := len(v) - 1; i >= 0; i-- {
-		v32[len(v)-1-i] = v[i]
+
+	return gfpFromWide(&t)
+}
+
+// gfpFromWide returns the big-endian integer in t, reduced modulo p, in
+// constant time.
+func gfpFromWide(t *[48]byte) *gfP {
+	// Split t into a 128-bit high part and a 256-bit low part, so that
+	// t·R = hi·R³/R + lo·R²/R.
+	hi, lo := &gfP{}, &gfP{}
+	for w := 0; w < 2; w++ {
+		hi[1-w] = binary.BigEndian.Uint64(t[8*w:])
 	}
-	u := &gfP{
-		binary.LittleEndian.Uint64(v32[0*8 : 1*8]),
-		binary.LittleEndian.Uint64(v32[1*8 : 2*8]),
-		binary.LittleEndian.Uint64(v32[2*8 : 3*8]),
-		binary.LittleEndian.Uint64(v32[3*8 : 4*8]),
+	for w := 0; w < 4; w++ {
+		lo[3-w] = binary.BigEndian.Uint64(t[16+8*w:])
 	}
-	montEncode(u, u)
+	gfpMul(hi, hi, r3)
+	gfpMul(lo, lo, r2)
+
+	u := &gfP{}
+	gfpAdd(u, hi, lo)
 	return u
 }
 
@@ -60,22 +71,36 @@ func (e *gfP) Set(f *gfP) {
 	e[3] = f[3]
 }
 
+// Cmov sets e to a if cond is 1 and leaves it unchanged if cond is 0, in
+// constant time.
+func (e *gfP) Cmov(a *gfP, cond int) {
+	mask := -uint64(cond)
+	e[0] ^= mask & (e[0] ^ a[0])
+	e[1] ^= mask & (e[1] ^ a[1])
+	e[2] ^= mask & (e[2] ^ a[2])
+	e[3] ^= mask & (e[3] ^ a[3])
+}
+
+// exp sets e to f^bits with a fixed-window addition chain. The sequence of
+// operations depends only on bits, which are always public constants, so its
+// timing does not depend on f.
 func (e *gfP) exp(f *gfP, bits [4]uint64) {
-	sum, power := &gfP{}, &gfP{}
-	sum.Set(rN1)
-	power.Set(f)
-
-	for word := 0; word < 4; word++ {
-		for bit := uint(0); bit < 64; bit++ {
-			if (bits[word]>>bit)&1 == 1 {
-				gfpMul(sum, sum, power)
-			}
-			gfpMul(power, power, power)
-		}
+	// table[i] is f^i.
+	table := [16]gfP{*r1, *f}
+	for i := 2; i < len(table); i++ {
+		gfpMul(&table[i], &table[i-1], f)
+	}
+
+	sum := table[0]
+	for i := 63; i >= 0; i-- {
+		gfpMul(&sum, &sum, &sum)
+		gfpMul(&sum, &sum, &sum)
+		gfpMul(&sum, &sum, &sum)
+		gfpMul(&sum, &sum, &sum)
+		gfpMul(&sum, &sum, &table[bits[i/16]>>(4*uint(i%16))&15])
 	}
 
-	gfpMul(sum, sum, r3)
-	e.Set(sum)
+	e.Set(&sum)
 }
 
 func (e *gfP) Invert(f *gfP) {
@@ -95,41 +120,55 @@ func (e *gfP) Marshal(out []byte) {
 	}
 }
 
-func (e *gfP) Unmarshal(in []byte) {
+// Unmarshal sets e to the big-endian integer in the first 32 bytes of in. It
+// returns ErrNonCanonical if that integer isn't strictly less than p, so that
+// every element of the field has exactly one encoding.
+func (e *gfP) Unmarshal(in []byte) error {
 	for w := uint(0); w < 4; w++ {
 		e[3-w] = 0
 		for b := uint(0); b < 8; b++ {
 			e[3-w] += uint64(in[8*w+b]) << (56 - 8*b)
 		}
 	}
+
+	for w := 3; w >= 0; w-- {
+		if e[w] < p2[w] {
+			return nil
+		} else if e[w] > p2[w] {
+			break
+		}
+	}
+	return ErrNonCanonical
 }
 
 func montEncode(c, a *gfP) { gfpMul(c, a, r2) }
 func montDecode(c, a *gfP) { gfpMul(c, a, &gfP{1}) }
 
+// gfpEqual returns 1 if a == b and 0 otherwise, in constant time.
+func gfpEqual(a, b *gfP) int {
+	v := (a[0] ^ b[0]) | (a[1] ^ b[1]) | (a[2] ^ b[2]) | (a[3] ^ b[3])
+	return int(1 ^ (v|-v)>>63)
+}
+
+// sign0 returns 1 if e, as an integer, is at least (p-1)/2 and -1 otherwise,
+// in constant time.
 func sign0(e *gfP) int {
 	x := &gfP{}
 	montDecode(x, e)
-	for w := 3; w >= 0; w-- {
-		if x[w] > pMinus1Over2[w] {
-			return 1
-		} else if x[w] < pMinus1Over2[w] {
-			return -1
-		}
+
+	var borrow uint64
+	for w := 0; w < 4; w++ {
+		_, borrow = bits.Sub64(x[w], pMinus1Over2[w], borrow)
 	}
-	return 1
+	return 1 - 2*int(borrow)
 }
 
+// legendre returns the Legendre symbol of e, in constant time.
 func legendre(e *gfP) int {
 	f := &gfP{}
 	// Since p = 4k+3, then e^(2k+1) is the Legendre symbol of e.
 	f.exp(e, pMinus1Over2)
 
-	montDecode(f, f)
-
-	if *f != [4]uint64{} {
-		return 2*int(f[0]&1) - 1
-	}
-
-	return 0
+	// f is 1, -1 or 0.
+	return 2*gfpEqual(f, newGFp(1)) + gfpEqual(f, &gfP{}) - 1
 }
@@ -55,6 +55,14 @@ func (e *gfP12) Set(a *gfP12) *gfP12 {
 	return e
 }
 
+// Cmov sets e to a if cond is 1 and leaves it unchanged if cond is 0, in
+// constant time, and then returns e.
+func (e *gfP12) Cmov(a *gfP12, cond int) *gfP12 {
+	e.x.Cmov(&a.x, cond)
+	e.y.Cmov(&a.y, cond)
+	return e
+}
+
 func (e *gfP12) SetZero() *gfP12 {
 	e.x.SetZero()
 	e.y.SetZero()
@@ -75,6 +83,29 @@ func (e *gfP12) IsOne() bool {
 	return e.x.IsZero() && e.y.IsOne()
 }
 
+// IsCyclotomic returns true iff e is in the cyclotomic subgroup of GF(p¹²),
+// the elements of order dividing p⁴-p²+1.
+func (e *gfP12) IsCyclotomic() bool {
+	t1 := (&gfP12{}).FrobeniusP4(e)
+	t1.Mul(t1, e)
+	t2 := (&gfP12{}).FrobeniusP2(e)
+
+	return *t1 == *t2 && !e.IsZero()
+}
+
+// IsInSubgroup returns true iff e is in GT, the subgroup of order Order.
+func (e *gfP12) IsInSubgroup() bool {
+	if !e.IsCyclotomic() {
+		return false
+	}
+
+	// Since Order = p-6u², e^Order = 1 iff e^p = e^(6u²).
+	t1 := (&gfP12{}).Frobenius(e)
+	t2 := (&gfP12{}).Exp(e, sixuSquared)
+
+	return *t1 == *t2
+}
+
 func (e *gfP12) Conjugate(a *gfP12) *gfP12 {
 	e.x.Neg(&a.x)
 	e.y.Set(&a.y)
@@ -174,6 +205,127 @@ func (e *gfP12) Square(a *gfP12) *gfP12 {
 	return e
 }
 
+// gfP4Square sets (c0, c1) to (a0+a1·s)² in GF(p⁴) = GF(p²)[s]/(s²-ξ).
+func gfP4Square(c0, c1, a0, a1 *gfP2) {
+	t0 := (&gfP2{}).Square(a0)
+	t1 := (&gfP2{}).Square(a1)
+
+	c1.Add(a0, a1).Square(c1).Sub(c1, t0).Sub(c1, t1)
+	c0.MulXi(t1).Add(c0, t0)
+}
+
+// CyclotomicSquare sets e to a² and then returns e. a must be in the
+// cyclotomic subgroup of GF(p¹²), which includes GT and the output of the
+// easy part of the final exponentiation.
+//
+// See "Faster Squaring in the Cyclotomic Subgroup of Sixth Degree
+// Extensions", Granger and Scott, section 3.2.
+// https://eprint.iacr.org/2009/565.pdf
+func (e *gfP12) CyclotomicSquare(a *gfP12) *gfP12 {
+	// Over GF(p⁴) = GF(p²)[s] with s = ω³, a = A + Bω + Cω² where
+	//   A = a.y.z + a.x.y·s, B = a.x.z + a.y.x·s, C = a.y.y + a.x.x·s.
+	// Then a² = (3A²-2Ā) + (3sC²+2B̄)ω + (3B²-2C̄)ω², where the bar is the
+	// conjugation s → -s, which is the p⁶-Frobenius.
+	a0, a1, b0, b1, c0, c1 := &gfP2{}, &gfP2{}, &gfP2{}, &gfP2{}, &gfP2{}, &gfP2{}
+	gfP4Square(a0, a1, &a.y.z, &a.x.y)
+	gfP4Square(b0, b1, &a.x.z, &a.y.x)
+	gfP4Square(c0, c1, &a.y.y, &a.x.x)
+	c1.MulXi(c1)
+
+	t := &gfP2{}
+
+	// 3A²-2Ā
+	t.Sub(a0, &a.y.z)
+	e.y.z.Add(t, t).Add(&e.y.z, a0)
+	t.Add(a1, &a.x.y)
+	e.x.y.Add(t, t).Add(&e.x.y, a1)
+
+	// 3sC²+2B̄, where sC² = c1 + c0·s after the MulXi above.
+	t.Add(c1, &a.x.z)
+	e.x.z.Add(t, t).Add(&e.x.z, c1)
+	t.Sub(c0, &a.y.x)
+	e.y.x.Add(t, t).Add(&e.y.x, c0)
+
+	// 3B²-2C̄
+	t.Sub(b0, &a.y.y)
+	e.y.y.Add(t, t).Add(&e.y.y, b0)
+	t.Add(b1, &a.x.x)
+	e.x.x.Add(t, t).Add(&e.x.x, b1)
+
+	return e
+}
+
+// CyclotomicExp sets e to a^power and then returns e. a must be in the
+// cyclotomic subgroup of GF(p¹²).
+func (e *gfP12) CyclotomicExp(a *gfP12, power *big.Int) *gfP12 {
+	sum := (&gfP12{}).SetOne()
+	t := &gfP12{}
+
+	for i := power.BitLen() - 1; i >= 0; i-- {
+		t.CyclotomicSquare(sum)
+		if power.Bit(i) != 0 {
+			sum.Mul(t, a)
+		} else {
+			sum.Set(t)
+		}
+	}
+
+	e.Set(sum)
+	return e
+}
+
+// ExpGLS sets e to a^power and then returns e. a must be in GT, because the
+// power is reduced modulo Order and split into four parts using the Frobenius,
+// which acts on GT as exponentiation by p ≡ 6u² (mod Order). Being in the
+// cyclotomic subgroup is not enough: for any other a, the result is wrong.
+func (e *gfP12) ExpGLS(a *gfP12, power *big.Int) *gfP12 {
+	ks := frobeniusLattice.decompose(power)
+
+	// The table holds products of a, a^p, a^p² and a^p³ where bit i of the
+	// index selects a^(pⁱ). Negative parts are handled by conjugating, which
+	// is inversion in the cyclotomic subgroup.
+	table := [16]gfP12{}
+	table[0].SetOne()
+	table[1].Set(a)
+	table[2].Frobenius(a)
+	table[4].FrobeniusP2(a)
+	table[8].Frobenius(&table[4])
+	for i, k := range ks {
+		if k.Sign() < 0 {
+			table[1<<uint(i)].Conjugate(&table[1<<uint(i)])
+			ks[i] = new(big.Int).Neg(k)
+		}
+	}
+	for i := 3; i < 16; i++ {
+		if low := i & -i; low != i {
+			table[i].Mul(&table[i-low], &table[low])
+		}
+	}
+
+	n := 0
+	for _, k := range ks {
+		if m := k.BitLen(); m > n {
+			n = m
+		}
+	}
+
+	sum := (&gfP12{}).SetOne()
+	t := &gfP12{}
+
+	for i := n - 1; i >= 0; i-- {
+		t.CyclotomicSquare(sum)
+		idx := ks[0].Bit(i) | ks[1].Bit(i)<<1 | ks[2].Bit(i)<<2 | ks[3].Bit(i)<<3
+		if idx != 0 {
+			sum.Mul(t, &table[idx])
+		} else {
+			sum.Set(t)
+		}
+	}
+
+	e.Set(sum)
+	return e
+}
+
 func (e *gfP12) Invert(a *gfP12) *gfP12 {
 	// See "Implementing cryptographic pairings", M. Scott, section 3.2.
 	// ftp://136.206.11.249/pub/crypto/pairings.pdf
@@ -4,6 +4,10 @@ package bn256
 // Pairing-Friendly Fields, Devegili et al.
 // http://eprint.iacr.org/2006/471.pdf.
 
+import (
+	"crypto/subtle"
+)
+
 // gfP2 implements a field of size p² as a quadratic extension of the base field
 // where i²=-1.
 type gfP2 struct {
@@ -27,6 +31,14 @@ func (e *gfP2) Set(a *gfP2) *gfP2 {
 	return e
 }
 
+// Cmov sets e to a if cond is 1 and leaves it unchanged if cond is 0, in
+// constant time, and then returns e.
+func (e *gfP2) Cmov(a *gfP2, cond int) *gfP2 {
+	e.x.Cmov(&a.x, cond)
+	e.y.Cmov(&a.y, cond)
+	return e
+}
+
 func (e *gfP2) SetZero() *gfP2 {
 	e.x = gfP{0}
 	e.y = gfP{0}
@@ -35,7 +47,7 @@ func (e *gfP2) SetZero() *gfP2 {
 
 func (e *gfP2) SetOne() *gfP2 {
 	e.x = gfP{0}
-	e.y = *newGFp(1)
+	e.y = *r1
 	return e
 }
 
@@ -45,7 +57,7 @@ func (e *gfP2) IsZero() bool {
 }
 
 func (e *gfP2) IsOne() bool {
-	zero, one := gfP{0}, *newGFp(1)
+	zero, one := gfP{0}, *r1
 	return e.x == zero && e.y == one
 }
 
@@ -148,3 +160,60 @@ func (e *gfP2) Invert(a *gfP2) *gfP2 {
 	gfpMul(&e.y, &a.y, inv)
 	return e
 }
+
+// Sqrt sets e to a square root of a and then returns e, in constant time. If a
+// is not a square then the result is meaningless, so callers must check that
+// e² = a.
+func (e *gfP2) Sqrt(a *gfP2) *gfP2 {
+	// If a = xi+y then its root is bi+c where c² = (y+λ)/2, b = x/2c and
+	// λ² = x²+y² is the norm of a. Exactly one choice of sign for λ makes
+	// (y+λ)/2 a non-zero square, unless x = 0 and y is not a square, in which
+	// case the root is bi with b² = -y. Every candidate is computed and the
+	// choices are made with conditional moves.
+	lambda, t := &gfP{}, &gfP{}
+	gfpMul(lambda, &a.x, &a.x)
+	gfpMul(t, &a.y, &a.y)
+	gfpAdd(lambda, lambda, t)
+	lambda.Sqrt(lambda)
+
+	delta, delta2 := &gfP{}, &gfP{}
+	gfpAdd(delta, &a.y, lambda)
+	gfpMul(delta, delta, twoInv)
+	gfpSub(delta2, &a.y, lambda)
+	gfpMul(delta2, delta2, twoInv)
+	delta.Cmov(delta2, subtle.ConstantTimeEq(int32(legendre(delta)), 1)^1)
+
+	b, c := &gfP{}, &gfP{}
+	c.Sqrt(delta)
+	gfpAdd(t, c, c)
+	t.Invert(t)
+	gfpMul(b, &a.x, t)
+
+	negY := &gfP{}
+	gfpNeg(negY, &a.y)
+	negY.Sqrt(negY)
+	imaginary := gfpEqual(&a.x, &gfP{}) & subtle.ConstantTimeEq(int32(legendre(&a.y)), -1)
+	b.Cmov(negY, imaginary)
+	c.Cmov(&gfP{}, imaginary)
+
+	e.x.Set(b)
+	e.y.Set(c)
+	return e
+}
+
+// sign0GFp2 returns the sign of the imaginary part of e, or of its real part
+// if the imaginary part is zero, in constant time.
+func sign0GFp2(e *gfP2) int {
+	return subtle.ConstantTimeSelect(gfpEqual(&e.x, &gfP{}), sign0(&e.y), sign0(&e.x))
+}
+
+// legendreGFp2 returns 1 if e is a non-zero square in GF(p²), -1 if it is not
+// a square and 0 if it is zero. An element is a square iff its norm x²+y² is a
+// square in GF(p).
+func legendreGFp2(e *gfP2) int {
+	norm, t := &gfP{}, &gfP{}
+	gfpMul(norm, &e.x, &e.x)
+	gfpMul(t, &e.y, &e.y)
+	gfpAdd(norm, norm, t)
+	return legendre(norm)
+}
@@ -21,6 +21,15 @@ func (e *gfP6) Set(a *gfP6) *gfP6 {
 	return e
 }
 
+// Cmov sets e to a if cond is 1 and leaves it unchanged if cond is 0, in
+// constant time, and then returns e.
+func (e *gfP6) Cmov(a *gfP6, cond int) *gfP6 {
+	e.x.Cmov(&a.x, cond)
+	e.y.Cmov(&a.y, cond)
+	e.z.Cmov(&a.z, cond)
+	return e
+}
+
 func (e *gfP6) SetZero() *gfP6 {
 	e.x.SetZero()
 	e.y.SetZero()
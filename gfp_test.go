@@ -163,4 +163,58 @@ func TestGFp(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("sqrt2", func(t *testing.T) {
+		for _, a := range []*gfP2{{gfP{0}, *newGFp(-1)}, {gfP{0}, *newGFp(4)}, {}} {
+			c := (&gfP2{}).Sqrt(a)
+			if got := (&gfP2{}).Square(c); *got != *a {
+				t.Errorf("got: %v want:%v", got, a)
+			}
+		}
+
+		for i := 0; i < testTimes; i++ {
+			a := &gfP2{*togfP(randomGF(rand.Reader)), *togfP(randomGF(rand.Reader))}
+			a.Square(a)
+
+			c := (&gfP2{}).Sqrt(a)
+			if got := (&gfP2{}).Square(c); *got != *a {
+				t.Errorf("got: %v want:%v", got, a)
+			}
+		}
+	})
+
+	t.Run("legendre", func(t *testing.T) {
+		for i := 0; i < testTimes; i++ {
+			bigA := randomGF(rand.Reader)
+			if i == 0 {
+				bigA.SetInt64(0)
+			}
+			want := big.Jacobi(bigA, p)
+
+			if got := legendre(togfP(bigA)); got != want {
+				t.Errorf("%v: got: %v want:%v", bigA, got, want)
+			}
+		}
+	})
+
+	t.Run("sign0", func(t *testing.T) {
+		half := new(big.Int).Rsh(p, 1)
+		for i := 0; i < testTimes; i++ {
+			bigA := randomGF(rand.Reader)
+			switch i {
+			case 0:
+				bigA.Set(half)
+			case 1:
+				bigA.Sub(half, big.NewInt(1))
+			}
+			want := -1
+			if bigA.Cmp(half) >= 0 {
+				want = 1
+			}
+
+			if got := sign0(togfP(bigA)); got != want {
+				t.Errorf("%v: got: %v want:%v", bigA, got, want)
+			}
+		}
+	})
 }
@@ -1,10 +1,46 @@
 package bn256
 
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"golang.org/x/crypto/hkdf"
+)
+
 // HashG1 implements a hashing function into the G1 group.
 //
 // dst represents domain separation tag, similar to salt, for the hash.
 func HashG1(msg, dst []byte) *G1 {
-	return mapToCurve(hashToBase(msg, dst))
+	return mapToCurve(hashToBase(msg, dst, 1))
+}
+
+// HashG2 implements a hashing function into the G2 group.
+//
+// dst represents domain separation tag, similar to salt, for the hash.
+func HashG2(msg, dst []byte) *G2 {
+	t := &gfP2{}
+	t.y.Set(hashToBase(msg, dst, 3))
+	t.x.Set(hashToBase(msg, dst, 4))
+
+	e := mapToTwist(t)
+	e.ClearCofactor(e)
+	return &G2{e}
+}
+
+// HashToScalar implements a hashing function into the integers modulo Order,
+// for deriving challenges and nonces from a transcript.
+//
+// dst represents domain separation tag, similar to salt, for the hash.
+func HashToScalar(msg, dst []byte) *Scalar {
+	// L = ceil((256+128)/8)=48 bytes, so that the bias of the reduction
+	// modulo Order is negligible.
+	var t [48]byte
+	info := []byte{'H', '2', 'S', byte(0), byte(1)}
+	r := hkdf.New(sha256.New, msg, dst, info)
+	if _, err := r.Read(t[:]); err != nil {
+		panic(err)
+	}
+	return new(Scalar).setWideBytes(&t)
 }
 
 func mapToCurve(t *gfP) *G1 {
@@ -32,50 +68,16 @@ func mapToCurve(t *gfP) *G1 {
 	gfpMul(w, st, st)
 	gfpMul(w, w, w0)
 
-	e := sign0(t)
-	cp := &curvePoint{z: one, t: one}
-
 	// calculate x1 = ((-1 + s) / 2) - t * w
 	tw := &gfP{}
 	gfpMul(tw, t, w)
 	x1 := &gfP{}
 	gfpSub(x1, sMinus1Over2, tw)
 
-	// check if y=x1^3+3 is a square
-	y := &gfP{}
-	y.Set(x1)
-	gfpMul(y, x1, x1)
-	gfpMul(y, y, x1)
-	gfpAdd(y, y, curveB)
-	if legendre(y) == 1 {
-		cp.x = *x1
-		y.Sqrt(y)
-		if e != sign0(y) {
-			gfpNeg(y, y)
-		}
-		cp.y = *y
-		return &G1{cp}
-	}
-
 	// calculate x2 = -1 - x1
 	x2 := newGFp(-1)
 	gfpSub(x2, x2, x1)
 
-	// check if y=x2^3+3 is a square
-	y.Set(x2)
-	gfpMul(y, x2, x2)
-	gfpMul(y, y, x2)
-	gfpAdd(y, y, curveB)
-	if legendre(y) == 1 {
-		cp.x = *x2
-		y.Sqrt(y)
-		if e != sign0(y) {
-			gfpNeg(y, y)
-		}
-		cp.y = *y
-		return &G1{cp}
-	}
-
 	// calculate x3 = 1 + (1/ww) = 1 + a^4 * w0^2
 	x3 := &gfP{}
 	gfpMul(x3, a, a)
@@ -84,17 +86,85 @@ func mapToCurve(t *gfP) *G1 {
 	gfpMul(x3, x3, w0)
 	gfpAdd(x3, x3, &one)
 
-	y.Set(x3)
-	gfpMul(y, x3, x3)
-	gfpMul(y, y, x3)
-	gfpAdd(y, y, curveB)
+	// Take the first of x1, x2 and x3 for which x^3+3 is a square. Every
+	// candidate is computed and checked, and the choice is made with
+	// conditional moves, so that the timing doesn't depend on t.
+	e1 := legendre(curveRHS(x1))
+	e2 := legendre(curveRHS(x2))
+
+	x := &gfP{}
+	x.Set(x3)
+	x.Cmov(x2, subtle.ConstantTimeEq(int32(e2), 1))
+	x.Cmov(x1, subtle.ConstantTimeEq(int32(e1), 1))
 
-	cp.x = *x3
+	y := curveRHS(x)
 	y.Sqrt(y)
-	if e != sign0(y) {
-		gfpNeg(y, y)
-	}
-	cp.y = *y
+	negY := &gfP{}
+	gfpNeg(negY, y)
+	y.Cmov(negY, subtle.ConstantTimeEq(int32(sign0(t)), int32(sign0(y)))^1)
 
+	cp := &curvePoint{x: *x, y: *y, z: one, t: one}
 	return &G1{cp}
 }
+
+// curveRHS returns x^3+3.
+func curveRHS(x *gfP) *gfP {
+	y := &gfP{}
+	gfpMul(y, x, x)
+	gfpMul(y, y, x)
+	gfpAdd(y, y, curveB)
+	return y
+}
+
+// mapToTwist is the same map as mapToCurve, over GF(p²) and onto the twist.
+// The result is generally not in G₂. As for mapToCurve, its timing does not
+// depend on t.
+func mapToTwist(t *gfP2) *twistPoint {
+	one := (&gfP2{}).SetOne()
+
+	// a = (1 + B + t^2), w = (s * t)/a = (st)^2 * w0 where w0 = 1/(st * a)
+	a := (&gfP2{}).Square(t)
+	a.Add(a, twistB).Add(a, one)
+
+	st := (&gfP2{}).MulScalar(t, s)
+	w0 := (&gfP2{}).Mul(st, a)
+	w0.Invert(w0)
+
+	w := (&gfP2{}).Square(st)
+	w.Mul(w, w0)
+
+	// x1 = ((-1 + s) / 2) - t * w
+	x1 := (&gfP2{}).Mul(t, w)
+	x1.Sub(&gfP2{y: *sMinus1Over2}, x1)
+
+	// x2 = -1 - x1
+	x2 := (&gfP2{}).Add(one, x1)
+	x2.Neg(x2)
+
+	// x3 = 1 + (1/ww) = 1 + a^4 * w0^2
+	x3 := (&gfP2{}).Square(a)
+	x3.Square(x3).Mul(x3, w0).Mul(x3, w0).Add(x3, one)
+
+	e1 := legendreGFp2(twistRHS(x1))
+	e2 := legendreGFp2(twistRHS(x2))
+
+	x := (&gfP2{}).Set(x3)
+	x.Cmov(x2, subtle.ConstantTimeEq(int32(e2), 1))
+	x.Cmov(x1, subtle.ConstantTimeEq(int32(e1), 1))
+
+	y := (&gfP2{}).Sqrt(twistRHS(x))
+	negY := (&gfP2{}).Neg(y)
+	y.Cmov(negY, subtle.ConstantTimeEq(int32(sign0GFp2(t)), int32(sign0GFp2(y)))^1)
+
+	c := &twistPoint{x: *x, y: *y}
+	c.z.SetOne()
+	c.t.SetOne()
+	return c
+}
+
+// twistRHS returns x^3+3/ξ.
+func twistRHS(x *gfP2) *gfP2 {
+	y := (&gfP2{}).Square(x)
+	y.Mul(y, x).Add(y, twistB)
+	return y
+}
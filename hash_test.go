@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"bytes"
+	"encoding/hex"
+	"strings"
 )
 
 func TestKnownHashes(t *testing.T) {
@@ -15,8 +17,227 @@ func TestKnownHashes(t *testing.T) {
 	}
 }
 
+func TestHashG2(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 16; i++ {
+		g := HashG2([]byte{byte(i)}, []byte("dst"))
+		if g.p.IsInfinity() || !g.p.IsOnCurve() || !g.IsInSubgroup() {
+			t.Fatalf("%d: hash is not a point of G2", i)
+		}
+
+		m := string(g.Marshal())
+		if seen[m] {
+			t.Fatalf("%d: repeated hash", i)
+		}
+		seen[m] = true
+
+		h := HashG2([]byte{byte(i)}, []byte("dst"))
+		if string(h.Marshal()) != m {
+			t.Fatalf("%d: hash is not deterministic", i)
+		}
+	}
+
+	if bytes.Equal(HashG2([]byte("abc"), []byte("a")).Marshal(), HashG2([]byte("abc"), []byte("b")).Marshal()) {
+		t.Error("domain separation tag is ignored")
+	}
+
+	// These vectors were computed with an independent implementation of
+	// hashToBase, mapToTwist and ClearCofactor.
+	vectors := []struct {
+		msg, dst []byte
+		want     string
+	}{
+		{[]byte{}, []byte{}, "016ce259badb77be27f232021401db2b6a5b35fb875b54a0d63e5b08dc91c727667f5e5eedd2d5ebf625d607103b13366db21ba9de97964adc0cbe3554c4a80eaf6cab0fdfca6b7452ce9b54515ffcb83736913f0ba9a8aae2b211614952e8f0ad89e388cc7b718cceb9f17a27eaf8b9f803c919a01bb72852c592aaf4119bf0ea"},
+		{[]byte("abc"), []byte("QUUX-V01-CS02"), "010cdcad80d6498f14d453900de8936fe69719caa80cdd07247fca609934112bfc193194eced42cf96fd2c65e2d04861fe893de1422150f87c24a7b33780f0a576094c53c2c7753fa57ea31d042979b28f0d6d2048428a6df34ce413a5340caabf561936b64ffe06569a1fb1df3ede56fab1c3c9e559befb3eca6eba574c561234"},
+		{[]byte("abcdef0123456789"), []byte("dst"), "010b315788b3a94bafd2f9a63662b55a6254880c8cd7facd1488e4fd313f883e5c38574079c4962827c9bd8e04b908069c1d291b3741a5002cef68cdd31f2391031c8a8f200a642ec38d5b7eb38e79fc646879452d2e07f8f0c1b2351485ef35dc1fe7aa0ba2ecb24f7fdd745f58ba7bdd8ab21556c4e839f8b85129a049df363d"},
+		{[]byte{0}, nil, "010a4cc06b83ea808d445f3d24bba8a29d08be80a16ff907fe82c11abf3133dea74583e2f5c30318fc9ef22f9299b7c570cf151934948c1e9fb122ee07a310d6c002aca894fc73fa9eb35a6d966bb1ce8abdc3e11550d82ad455041c297acdf21f202eb01a919723e7963cd47cf2235bc963515cb9f2f758cec7d538c3dcb2ae5d"},
+	}
+	for _, v := range vectors {
+		if got := HashG2(v.msg, v.dst).Marshal(); hex.EncodeToString(got) != v.want {
+			t.Errorf("HashG2(%q, %q) = %x, want %s", v.msg, v.dst, got, v.want)
+		}
+	}
+}
+
+func TestMapToTwist(t *testing.T) {
+	for i := int64(0); i < 16; i++ {
+		u := &gfP2{*newGFp(i), *newGFp(3 - i)}
+		if !mapToTwist(u).IsOnCurve() {
+			t.Fatalf("%v: point is not on the twist", u)
+		}
+	}
+
+	// When t = 0 or 1+b'+t² = 0, w0 inverts 0, which gives 0. Then w = 0 and
+	// the map picks x1 = (s-1)/2, with the sign of y taken from t.
+	zero := &gfP2{}
+	root := (&gfP2{}).SetOne()
+	root.Add(root, twistB).Neg(root)
+	root.Sqrt(root)
+	for _, v := range []struct {
+		t    *gfP2
+		want string
+	}{
+		{zero, "011f427e4c7a23fc4c6c3f670531f26f504f88d1c117d3c4543626767d4b5e0f2d66a813c3e0c8d4c9f4b41adffe77451b0466fe510957e135af8b6867b3feb1af8a5f3a3b68b1163ca6ec86fbbe0eeadf9fc24893295cfa6cb1f2d6f5242829970283a4c6f445422e6dd1b064bd3d712899012e3b6177e6ecd2484b09287e562d"},
+		{root, "011f427e4c7a23fc4c6c3f670531f26f504f88d1c117d3c4543626767d4b5e0f2d66a813c3e0c8d4c9f4b41adffe77451b0466fe510957e135af8b6867b3feb1af0555c7a7e1f271bd038365bca375f1424e99403df758bb316669d57739e06cd08d315d1c565e45cb3c9e3c53a4476af9555a5a95bf3dceb146146163358a403a"},
+	} {
+		e := mapToTwist(v.t)
+		if !e.IsOnCurve() {
+			t.Fatalf("%v: point is not on the twist", v.t)
+		}
+		e.ClearCofactor(e)
+		if got := (&G2{e}).Marshal(); hex.EncodeToString(got) != v.want {
+			t.Errorf("%v: got %x, want %s", v.t, got, v.want)
+		}
+	}
+}
+
+func TestHashToScalar(t *testing.T) {
+	vectors := []struct {
+		msg, dst []byte
+		want     string
+	}{
+		{[]byte{}, []byte{}, "5a47de0b772906480607def77ad09f6fa76953475b8bb64f63269e78baa02b8a"},
+		{[]byte("abc"), []byte("QUUX-V01-CS02"), "0bceeb12e1de309abc0f7ed2563b18d9f44cdf1a28700eca70893e6a3772c2ac"},
+		{[]byte{1}, nil, "84818cff4e1e4dd46fe65b891c3286652056f37a5d19375956b73f0ebfcb4dd7"},
+	}
+	for _, v := range vectors {
+		got := HashToScalar(v.msg, v.dst)
+		if hex.EncodeToString(got.Marshal()) != v.want {
+			t.Errorf("HashToScalar(%q, %q) = %x, want %s", v.msg, v.dst, got.Marshal(), v.want)
+		}
+	}
+
+	if HashToScalar([]byte("abc"), []byte("a")).Equal(HashToScalar([]byte("abc"), []byte("b"))) {
+		t.Error("domain separation tag is ignored")
+	}
+}
+
+func TestExpandMessage(t *testing.T) {
+	// The first two vectors of each expander are from RFC 9380, appendix K.
+	xmdDST := "QUUX-V01-CS02-with-expander-SHA256-128"
+	xofDST := "QUUX-V01-CS02-with-expander-SHAKE128"
+	vectors := []struct {
+		expand   func(msg, dst []byte, n int) []byte
+		msg, dst string
+		n        int
+		want     string
+	}{
+		{expandMessageXMD, "", xmdDST, 0x20, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{expandMessageXMD, "abc", xmdDST, 0x20, "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+		{expandMessageXMD, "", xmdDST, 0x80, "af84c27ccfd45d41914fdff5df25293e221afc53d8ad2ac06d5e3e29485dadbee0d121587713a3e0dd4d5e69e93eb7cd4f5df4cd103e188cf60cb02edc3edf18eda8576c412b18ffb658e3dd6ec849469b979d444cf7b26911a08e63cf31f9dcc541708d3491184472c2c29bb749d4286b004ceb5ee6b9a7fa5b646c993f0ced"},
+		{expandMessageXMD, "abc", xmdDST + "-long-DST-" + strings.Repeat("1", 202), 0x20, "2eeaf98750dce19f57364f2e5ad1a19010f1d150cbeebb2b2654ec99c845d3e2"},
+		{expandMessageXOF, "", xofDST, 0x20, "86518c9cd86581486e9485aa74ab35ba150d1c75c88e26b7043e44e2acd735a2"},
+		{expandMessageXOF, "abc", xofDST, 0x20, "8696af52a4d862417c0763556073f47bc9b9ba43c99b505305cb1ec04a9ab468"},
+		{expandMessageXOF, "", xofDST, 0x80, "7314ff1a155a2fb99a0171dc71b89ab6e3b2b7d59e38e64419b8b6294d03ffee42491f11370261f436220ef787f8f76f5b26bdcd850071920ce023f3ac46847744f4612b8714db8f5db83205b2e625d95afd7d7b4d3094d3bdde815f52850bb41ead9822e08f22cf41d615a303b0d9dde73263c049a7b9898208003a739a2e57"},
+		{expandMessageXOF, "abc", xofDST + "-long-DST-" + strings.Repeat("1", 211), 0x20, "8e0ea453419adff803bbaf5dd55c8965883cfc28190786b5d73ec369af775492"},
+	}
+	for i, v := range vectors {
+		got := v.expand([]byte(v.msg), []byte(v.dst), v.n)
+		if hex.EncodeToString(got) != v.want {
+			t.Errorf("%d: got %x, want %s", i, got, v.want)
+		}
+	}
+}
+
+func TestHashToG1(t *testing.T) {
+	// RFC 9380 doesn't define suites for this curve, so these vectors were
+	// computed with an independent implementation of the RFC, following the
+	// format of appendix J.
+	type vector struct {
+		msg, x, y string
+	}
+	suites := []struct {
+		name    string
+		hash    func(msg, dst []byte) *G1
+		vectors []vector
+	}{
+		{"XMD:SHA-256_SVDW_RO_", HashToG1, []vector{
+			{"", "24806e759b4a774899c983aad9032f5bf7570d2320896c99a181e2fdeb12bb33", "76b08d168cf7755a0a094881a48f5a19f32d7146bb66d5914b7488422291150d"},
+			{"abc", "64ae303357450c22fee03159020f3d847de6d27a19d58da9cf4f2688ce42e31e", "5e5afd6b978975f0d2644ff3f3e611580f442b1aaa09faf74fc6ad6762b5ec55"},
+			{"abcdef0123456789", "13dd8022b75d2f8b2305255257fb2b5fdc283ca9e08a68aaebfa074a3e22fb24", "5424bfa2f8b514bb406b846d1da502eaef57e720622fed8fe79c005e20d803ce"},
+			{"q128_" + strings.Repeat("q", 128), "4ca146c352e451fd9e7dec0120a4c21ed0f1e379d80df4add98c2725e555b20c", "1f1c574398b9bcc9221b630e04616d8b9ac6b02dd641d39ec2ec047a2c42bef7"},
+			{"a512_" + strings.Repeat("a", 512), "3d7af00e53a54e34f6cff2201ea0f42f27ac836fa1ab84623cb5782503972187", "28a06a90e87e54eed0d94e3cd162f1f974357e792722efbeca264b958c89b21e"},
+		}},
+		{"XMD:SHA-256_SVDW_NU_", EncodeToG1, []vector{
+			{"", "54c4d8ee325b07a7185deacf63e391e42e5043aaadb5200119e54ee5c8665a59", "15646127c53e0b384a31082e5e88a327f10920059dad7d9dc2b8bb6178fe5981"},
+			{"abc", "5e55d7580f9a2ff63eb38dcaad43b6418ab22bbecc3005da1c0408a97aef9801", "479d8e4d3e46140db0a684e3d4464c9bb9f36b4322e424bae20d857b1ea4dbb0"},
+			{"abcdef0123456789", "4ce35fb64c1346355c2f29d547029e434aa44d19cdcd479acfb27f4a6ff47614", "164c34887c2a51ecb798b41837fa141633ca3db07273549a37ce09ff0b93ce54"},
+			{"q128_" + strings.Repeat("q", 128), "8bfe6f9a1242c75ed76895c84245443b53e446b65cfd59d2b789cd026e8c8dbf", "0c5de05ffc737c2031ad848f913de6c9359d45105c028dcb3f059a60427a7f19"},
+			{"a512_" + strings.Repeat("a", 512), "123b0d8e4f92f99cb610ed0abb9117ad2fa71277e5ddff9164895c20f1ebc4df", "339e36b50db95963d56924b575fe51f54166ca548846e8af0766f4473a1633f4"},
+		}},
+		{"XOF:SHAKE128_SVDW_RO_", HashToG1XOF, []vector{
+			{"", "2c2796d74d01ecf3441c4b594569535f0e1361a8f4dc176e7baae2adc70d21d1", "6b3288d7c6e3ee28eaf87289e498a1e2086bf7f351fe82f89e89858eb7ce77f7"},
+			{"abc", "4f22ae057cdcfd32ce12a99501dbaa091b07b8bfeef9048a17de3888146b59be", "5e066d3a666a81c1ded5eb42611cff5c18c12029feada388591388bdc73bc4f6"},
+			{"abcdef0123456789", "2862b60c57d0446dd63d7e821c05583de0ca809f641bda82253671aae71ab27a", "4b98ebe0e91012194d7e6ae749e2024b852ed9c0dba2d736819148d6f8bc3c24"},
+			{"q128_" + strings.Repeat("q", 128), "2c48250284be2684863c47f4d8a0d315e88053241058a24c575fd4df89da2fa6", "398b01a880d7f1cc8a2f622fdd851ee4c584029ae9d1517634379ab7769e0b2e"},
+			{"a512_" + strings.Repeat("a", 512), "2eb6042a8c19c685195622ba8ffa531ffe1153c0878261b183a449b0ed4d8a40", "2ae9802594a9f789c157e0a0586dd3381df0a1d16088059c7d9b79b5cac19966"},
+		}},
+		{"XOF:SHAKE128_SVDW_NU_", EncodeToG1XOF, []vector{
+			{"", "5e706ae506cd0340e0fea68b022fc0e719e603b6e5d816172d6531fda8df6c0d", "794d16e30fd9729774ca5fba205e73e8c90b6f7aa01e59d44fff42eafe0bdda6"},
+			{"abc", "2733b217bf41a8ecb3268a6a8d98ab849384e226b98f94771fa2cdc6d7d71556", "4e73cb3d1f2dbed982eabf518cb7f03c00c11f8add65449ec5e8e94d219811bd"},
+			{"abcdef0123456789", "32590370e7d5741be84223650e23257c419f94aa045f7e167782244a108fc408", "8a30acb48549f7ca00959c1c33e9133aa1db37472a45d5d500d407953553aff3"},
+			{"q128_" + strings.Repeat("q", 128), "814ff5382915c8c0f2eea19289fcc526e7092b100cd8c9987edfe239b7cb2e24", "651af8df0a60f6468d3d22926e5db77aa5198e7126e716081f42f4fcb54e0a32"},
+			{"a512_" + strings.Repeat("a", 512), "460ac8fa463b997111f3b616204f1aac2c65483e9ab1e384befb60bc983ce203", "4e535f69f8ce7e0f81c1e472b58c90809efbcd5bcaa24eaf58a655b5fc7c141d"},
+		}},
+	}
+	for _, s := range suites {
+		dst := []byte("QUUX-V01-CS02-with-BN256G1_" + s.name)
+		for _, v := range s.vectors {
+			got := hex.EncodeToString(s.hash([]byte(v.msg), dst).Marshal())
+			if got != v.x+v.y {
+				t.Errorf("%s(%.16q) = %s, want %s%s", s.name, v.msg, got, v.x, v.y)
+			}
+		}
+	}
+}
+
+func TestSvdwMap(t *testing.T) {
+	// u = 1/2 makes 1-4u² zero, the exceptional case of inv0.
+	us := []*gfP{twoInv}
+	for i := int64(-8); i < 8; i++ {
+		us = append(us, newGFp(i))
+	}
+	for _, u := range us {
+		if !svdwMap(u).IsOnCurve() {
+			t.Fatalf("%v: point is not on the curve", u)
+		}
+	}
+}
+
+func TestMapToCurveInverse(t *testing.T) {
+	for i := int64(-8); i < 8; i++ {
+		u := newGFp(i)
+		g := mapToCurve(u)
+
+		found := false
+		ts := mapToCurvePreimages(g.p)
+		for _, t2 := range ts {
+			found = found || *t2 == *u
+			if h := mapToCurve(t2); !bytes.Equal(h.Marshal(), g.Marshal()) {
+				t.Fatalf("%v: preimage %v maps to a different point", u, t2)
+			}
+		}
+		if !found {
+			t.Fatalf("%v: not among the %d preimages of its image", u, len(ts))
+		}
+	}
+}
+
 var buf = make([]byte, 8192)
 
+func BenchmarkHashToG1(b *testing.B) {
+	dst := []byte("QUUX-V01-CS02-with-BN256G1_XMD:SHA-256_SVDW_RO_")
+	for i := 0; i < b.N; i++ {
+		HashToG1(buf[:32], dst)
+	}
+}
+
+func BenchmarkHashG2(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		HashG2(buf[:32], nil)
+	}
+}
+
 func benchmarkSize(b *testing.B, size int) {
 	b.SetBytes(int64(size))
 	for i := 0; i < b.N; i++ {
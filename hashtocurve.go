@@ -0,0 +1,212 @@
+package bn256
+
+// This file implements the hash_to_curve and encode_to_curve functions of RFC
+// 9380 for G₁, with the Shallue-van de Woestijne map of section 6.6.1. The
+// suites are
+//
+//	BN256G1_XMD:SHA-256_SVDW_RO_ and BN256G1_XMD:SHA-256_SVDW_NU_
+//	BN256G1_XOF:SHAKE128_SVDW_RO_ and BN256G1_XOF:SHAKE128_SVDW_NU_
+//
+// with L = 48 and h_eff = 1. https://www.rfc-editor.org/rfc/rfc9380
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// h2cFieldBytes is L = ceil((ceil(log2(p))+k)/8) for k = 128.
+const h2cFieldBytes = 48
+
+// HashToG1 implements hash_to_curve from RFC 9380 with the suite
+// BN256G1_XMD:SHA-256_SVDW_RO_. Its output is indistinguishable from a random
+// oracle.
+//
+// dst is the domain separation tag, and must be unique to the application and
+// the protocol that uses it.
+func HashToG1(msg, dst []byte) *G1 {
+	return hashToG1(expandMessageXMD(msg, dst, 2*h2cFieldBytes))
+}
+
+// EncodeToG1 implements encode_to_curve from RFC 9380 with the suite
+// BN256G1_XMD:SHA-256_SVDW_NU_. It is about twice as fast as HashToG1, but its
+// output is not uniformly distributed in G₁.
+//
+// dst is the domain separation tag, and must be unique to the application and
+// the protocol that uses it.
+func EncodeToG1(msg, dst []byte) *G1 {
+	return encodeToG1(expandMessageXMD(msg, dst, h2cFieldBytes))
+}
+
+// HashToG1XOF is the same as HashToG1, with the suite
+// BN256G1_XOF:SHAKE128_SVDW_RO_.
+func HashToG1XOF(msg, dst []byte) *G1 {
+	return hashToG1(expandMessageXOF(msg, dst, 2*h2cFieldBytes))
+}
+
+// EncodeToG1XOF is the same as EncodeToG1, with the suite
+// BN256G1_XOF:SHAKE128_SVDW_NU_.
+func EncodeToG1XOF(msg, dst []byte) *G1 {
+	return encodeToG1(expandMessageXOF(msg, dst, h2cFieldBytes))
+}
+
+// hashToG1 maps the two field elements in uniform to G₁ and adds them.
+func hashToG1(uniform []byte) *G1 {
+	u0, u1 := hashToField(uniform[:h2cFieldBytes]), hashToField(uniform[h2cFieldBytes:])
+	q0, q1 := svdwMap(u0), svdwMap(u1)
+
+	e := &curvePoint{}
+	e.Add(q0, q1)
+	return &G1{e}
+}
+
+// encodeToG1 maps the field element in uniform to G₁.
+func encodeToG1(uniform []byte) *G1 {
+	return &G1{svdwMap(hashToField(uniform))}
+}
+
+// hashToField reduces the h2cFieldBytes bytes in b, read as a big-endian
+// integer, modulo p.
+func hashToField(b []byte) *gfP {
+	var t [h2cFieldBytes]byte
+	copy(t[:], b)
+	return gfpFromWide(&t)
+}
+
+// expandMessageXMD implements expand_message_xmd from RFC 9380, section
+// 5.3.1, with SHA-256.
+func expandMessageXMD(msg, dst []byte, n int) []byte {
+	if len(dst) > 255 {
+		h := sha256.New()
+		h.Write([]byte("H2C-OVERSIZE-DST-"))
+		h.Write(dst)
+		dst = h.Sum(nil)
+	}
+	ell := (n + sha256.Size - 1) / sha256.Size
+	if ell > 255 || n > 65535 {
+		panic("bn256: requested too many bytes from expand_message_xmd")
+	}
+	dstPrime := append(dst[:len(dst):len(dst)], byte(len(dst)))
+
+	var lenInBytes [2]byte
+	binary.BigEndian.PutUint16(lenInBytes[:], uint16(n))
+
+	h := sha256.New()
+	h.Write(make([]byte, sha256.BlockSize))
+	h.Write(msg)
+	h.Write(lenInBytes[:])
+	h.Write([]byte{0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	out := make([]byte, 0, ell*sha256.Size)
+	bi := make([]byte, sha256.Size)
+	for i := 1; i <= ell; i++ {
+		// b_i = H(strxor(b_0, b_(i-1)) || i || DST_prime), where b_0 is
+		// used in place of strxor(b_0, b_0) for i = 1.
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		h.Reset()
+		h.Write(bi)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(bi[:0])
+		out = append(out, bi...)
+	}
+
+	return out[:n]
+}
+
+// expandMessageXOF implements expand_message_xof from RFC 9380, section
+// 5.3.2, with SHAKE128.
+func expandMessageXOF(msg, dst []byte, n int) []byte {
+	if len(dst) > 255 {
+		h := sha3.NewShake128()
+		h.Write([]byte("H2C-OVERSIZE-DST-"))
+		h.Write(dst)
+		dst = make([]byte, 32)
+		h.Read(dst)
+	}
+	if n > 65535 {
+		panic("bn256: requested too many bytes from expand_message_xof")
+	}
+
+	var lenInBytes [2]byte
+	binary.BigEndian.PutUint16(lenInBytes[:], uint16(n))
+
+	h := sha3.NewShake128()
+	h.Write(msg)
+	h.Write(lenInBytes[:])
+	h.Write(dst)
+	h.Write([]byte{byte(len(dst))})
+
+	out := make([]byte, n)
+	h.Read(out)
+	return out
+}
+
+// svdwMap implements the Shallue-van de Woestijne map of RFC 9380, section
+// 6.6.1, with the straight-line procedure of appendix F.1. Its timing does not
+// depend on u.
+func svdwMap(u *gfP) *curvePoint {
+	one := newGFp(1)
+
+	tv1, tv2, tv3, tv4 := &gfP{}, &gfP{}, &gfP{}, &gfP{}
+	gfpMul(tv1, u, u)
+	gfpMul(tv1, tv1, svdwC1)
+	gfpAdd(tv2, one, tv1)
+	gfpSub(tv1, one, tv1)
+	gfpMul(tv3, tv1, tv2)
+	tv3.Invert(tv3) // inv0, since 0^(p-2) = 0
+	gfpMul(tv4, u, tv1)
+	gfpMul(tv4, tv4, tv3)
+	gfpMul(tv4, tv4, svdwC3)
+
+	// x1 = c2 - tv4 and x2 = c2 + tv4
+	x1, x2 := &gfP{}, &gfP{}
+	gfpSub(x1, svdwC2, tv4)
+	gfpAdd(x2, svdwC2, tv4)
+	e1 := rfcIsSquare(curveRHS(x1))
+	e2 := rfcIsSquare(curveRHS(x2)) &^ e1
+
+	// x3 = c4·(tv2²·tv3)² + Z
+	x3 := &gfP{}
+	gfpMul(x3, tv2, tv2)
+	gfpMul(x3, x3, tv3)
+	gfpMul(x3, x3, x3)
+	gfpMul(x3, x3, svdwC4)
+	gfpAdd(x3, x3, svdwZ)
+
+	x := &gfP{}
+	x.Set(x3)
+	x.Cmov(x1, e1)
+	x.Cmov(x2, e2)
+
+	y := curveRHS(x)
+	y.Sqrt(y)
+	negY := &gfP{}
+	gfpNeg(negY, y)
+	y.Cmov(negY, subtle.ConstantTimeEq(int32(rfcSgn0(u)), int32(rfcSgn0(y)))^1)
+
+	c := &curvePoint{x: *x, y: *y}
+	c.z.Set(one)
+	c.t.Set(one)
+	return c
+}
+
+// rfcIsSquare returns 1 if e is a square, including 0, and 0 otherwise, in
+// constant time.
+func rfcIsSquare(e *gfP) int {
+	return subtle.ConstantTimeEq(int32(legendre(e)), -1) ^ 1
+}
+
+// rfcSgn0 returns the parity of e as an integer between 0 and p-1, which is
+// the sgn0 function of RFC 9380, section 4.1. It differs from sign0.
+func rfcSgn0(e *gfP) int {
+	x := &gfP{}
+	montDecode(x, e)
+	return int(x[0] & 1)
+}
@@ -0,0 +1,98 @@
+package bn256
+
+// This file implements scalar decomposition for the GLV and GLS methods. See
+// "Faster Point Multiplication on Elliptic Curves with Efficient
+// Endomorphisms", Gallant, Lambert and Vanstone, and "Endomorphisms for Faster
+// Elliptic Curve Cryptography on a Large Class of Curves", Galbraith, Lin and
+// Scott. https://eprint.iacr.org/2008/194.pdf
+
+import (
+	"math/big"
+)
+
+// lattice is a reduced basis of the lattice of vectors (k₀, k₁, ...) such
+// that Σ kᵢ·λⁱ ≡ 0 (mod Order), where λ is the eigenvalue of an endomorphism.
+// inverse is the first row of the adjugate of the basis, so that
+// k·inverse[i]/det are the coordinates of (k, 0, ...) with respect to vectors.
+type lattice struct {
+	vectors [][]*big.Int
+	inverse []*big.Int
+	det     *big.Int
+}
+
+// curveLattice is the lattice for the endomorphism (x, y) → (βx, y) of G₁,
+// where β = xiTo2PSquaredMinus2Over3. Its eigenvalue is 36u³+18u²+6u+1.
+var curveLattice = &lattice{
+	vectors: [][]*big.Int{
+		{bigFromBase10("-13037178982157583875"), bigFromBase10("254952053719217182009119236802174855688")},
+		{bigFromBase10("254952053719217182022156415784332439563"), bigFromBase10("13037178982157583875")},
+	},
+	inverse: []*big.Int{
+		bigFromBase10("-13037178982157583875"),
+		bigFromBase10("254952053719217182009119236802174855688"),
+	},
+	det: Order,
+}
+
+// frobeniusLattice is the lattice for endomorphisms whose eigenvalue is
+// p ≡ 6u² (mod Order): the untwist-Frobenius-twist map ψ on G₂ and the
+// Frobenius on GT.
+var frobeniusLattice = &lattice{
+	vectors: [][]*big.Int{
+		{bigFromBase10("13037178982157583875"), bigFromBase10("0"), bigFromBase10("13037178982157583874"), bigFromBase10("1")},
+		{bigFromBase10("13037178982157583874"), bigFromBase10("6518589491078791938"), bigFromBase10("-6518589491078791937"), bigFromBase10("6518589491078791937")},
+		{bigFromBase10("6518589491078791938"), bigFromBase10("6518589491078791937"), bigFromBase10("6518589491078791937"), bigFromBase10("-13037178982157583874")},
+		{bigFromBase10("13037178982157583875"), bigFromBase10("-6518589491078791937"), bigFromBase10("-6518589491078791938"), bigFromBase10("-6518589491078791937")},
+	},
+	inverse: []*big.Int{
+		bigFromBase10("1661927778103044753715912134891588971240935301695855419406"),
+		bigFromBase10("1661927778103044753460960081172371789225297475402601771781"),
+		bigFromBase10("13037178982157583875"),
+		bigFromBase10("1661927778103044753715912134891588971234416712204776627469"),
+	},
+	det: Order,
+}
+
+// decompose reduces k modulo Order and returns a short vector (k₀, k₁, ...)
+// such that Σ kᵢ·λⁱ ≡ k (mod Order). The components may be negative.
+func (l *lattice) decompose(k *big.Int) []*big.Int {
+	n := len(l.inverse)
+
+	if k.Sign() < 0 || k.Cmp(Order) >= 0 {
+		k = new(big.Int).Mod(k, Order)
+	}
+
+	// Find the closest lattice vector to (k, 0, ...) with Babai's rounding
+	// technique.
+	c := make([]*big.Int, n)
+	for i := 0; i < n; i++ {
+		c[i] = new(big.Int).Mul(k, l.inverse[i])
+		round(c[i], l.det)
+	}
+
+	// Subtract it from (k, 0, ...).
+	out := make([]*big.Int, n)
+	temp := new(big.Int)
+	for i := 0; i < n; i++ {
+		out[i] = new(big.Int)
+		for j := 0; j < n; j++ {
+			temp.Mul(c[j], l.vectors[j][i])
+			out[i].Sub(out[i], temp)
+		}
+	}
+	out[0].Add(out[0], k)
+
+	return out
+}
+
+// round sets num to num/denom rounded to the nearest integer. denom must be
+// positive.
+func round(num, denom *big.Int) {
+	r := new(big.Int)
+	num.DivMod(num, denom, r)
+
+	r.Lsh(r, 1)
+	if r.Cmp(denom) >= 0 {
+		num.Add(num, big.NewInt(1))
+	}
+}
@@ -0,0 +1,196 @@
+package bn256
+
+// This file implements multi-scalar multiplication with Pippenger's bucket
+// method. See section 4 of "Faster batch forgery identification", Bernstein
+// et al. https://eprint.iacr.org/2012/549.pdf
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// scalarWords reduces k modulo Order and returns it as little-endian 64-bit
+// words. As with Scalar.SetBigInt, its timing depends only on the length of k.
+func scalarWords(k *big.Int) [4]uint64 {
+	var s Scalar
+	return s.SetBigInt(k).words()
+}
+
+// scalarWindow returns the c bits of k starting at bit i.
+func scalarWindow(k *[4]uint64, i, c uint) uint {
+	w, shift := i/64, i%64
+	out := k[w] >> shift
+	if shift+c > 64 && w < 3 {
+		out |= k[w+1] << (64 - shift)
+	}
+	return uint(out & (1<<c - 1))
+}
+
+// msmWindowSize returns the window size that minimises the cost of
+// Pippenger's method for n points, which is roughly (256/c)·(n+2^c)
+// additions.
+func msmWindowSize(n int) uint {
+	c := bits.Len(uint(n)) - 2
+	if c < 3 {
+		return 3
+	} else if c > 16 {
+		return 16
+	}
+	return uint(c)
+}
+
+// curvePointsMakeAffine converts every point in ps to affine form with a
+// single field inversion, using Montgomery's trick.
+func curvePointsMakeAffine(ps []curvePoint) {
+	prods := make([]gfP, len(ps))
+	acc := *newGFp(1)
+	for i := range ps {
+		prods[i] = acc
+		if !ps[i].IsInfinity() {
+			gfpMul(&acc, &acc, &ps[i].z)
+		}
+	}
+
+	// acc is now the product of all non-zero z-coordinates and prods[i] is
+	// the product of those before ps[i].
+	inv := &gfP{}
+	inv.Invert(&acc)
+
+	zInv, zInv2 := &gfP{}, &gfP{}
+	for i := len(ps) - 1; i >= 0; i-- {
+		c := &ps[i]
+		if c.IsInfinity() {
+			c.SetInfinity()
+			continue
+		}
+
+		gfpMul(zInv, inv, &prods[i])
+		gfpMul(inv, inv, &c.z)
+
+		gfpMul(zInv2, zInv, zInv)
+		gfpMul(&c.x, &c.x, zInv2)
+		gfpMul(zInv2, zInv2, zInv)
+		gfpMul(&c.y, &c.y, zInv2)
+		c.z = *newGFp(1)
+		c.t = *newGFp(1)
+	}
+}
+
+// twistPointsMakeAffine converts every point in ps to affine form with a
+// single field inversion, using Montgomery's trick.
+func twistPointsMakeAffine(ps []twistPoint) {
+	prods := make([]gfP2, len(ps))
+	acc := (&gfP2{}).SetOne()
+	for i := range ps {
+		prods[i] = *acc
+		if !ps[i].IsInfinity() {
+			acc.Mul(acc, &ps[i].z)
+		}
+	}
+
+	inv := (&gfP2{}).Invert(acc)
+
+	zInv, zInv2 := &gfP2{}, &gfP2{}
+	for i := len(ps) - 1; i >= 0; i-- {
+		c := &ps[i]
+		if c.IsInfinity() {
+			c.SetInfinity()
+			continue
+		}
+
+		zInv.Mul(inv, &prods[i])
+		inv.Mul(inv, &c.z)
+
+		zInv2.Square(zInv)
+		c.x.Mul(&c.x, zInv2)
+		zInv2.Mul(zInv2, zInv)
+		c.y.Mul(&c.y, zInv2)
+		c.z.SetOne()
+		c.t.SetOne()
+	}
+}
+
+// MultiMul sets c to the sum of ps[i]*scalars[i].
+func (c *curvePoint) MultiMul(ps []*curvePoint, scalars []*big.Int) {
+	affine := make([]curvePoint, len(ps))
+	ks := make([][4]uint64, len(ps))
+	for i := range ps {
+		affine[i].Set(ps[i])
+		ks[i] = scalarWords(scalars[i])
+	}
+	curvePointsMakeAffine(affine)
+
+	w := msmWindowSize(len(ps))
+	buckets := make([]curvePoint, 1<<w-1)
+	sum, running, windowSum := &curvePoint{}, &curvePoint{}, &curvePoint{}
+	sum.SetInfinity()
+
+	for i := int((256+w-1)/w) - 1; i >= 0; i-- {
+		for j := uint(0); j < w; j++ {
+			sum.Double(sum)
+		}
+
+		for j := range buckets {
+			buckets[j].SetInfinity()
+		}
+		for j := range affine {
+			if d := scalarWindow(&ks[j], uint(i)*w, w); d != 0 {
+				buckets[d-1].AddMixed(&buckets[d-1], &affine[j])
+			}
+		}
+
+		// Σ d·buckets[d-1] = Σ_d Σ_{j ≥ d} buckets[j-1]
+		running.SetInfinity()
+		windowSum.SetInfinity()
+		for j := len(buckets) - 1; j >= 0; j-- {
+			running.Add(running, &buckets[j])
+			windowSum.Add(windowSum, running)
+		}
+		sum.Add(sum, windowSum)
+	}
+
+	c.Set(sum)
+}
+
+// MultiMul sets c to the sum of ps[i]*scalars[i].
+func (c *twistPoint) MultiMul(ps []*twistPoint, scalars []*big.Int) {
+	// For additional comments, see the same function for curvePoint.
+
+	affine := make([]twistPoint, len(ps))
+	ks := make([][4]uint64, len(ps))
+	for i := range ps {
+		affine[i].Set(ps[i])
+		ks[i] = scalarWords(scalars[i])
+	}
+	twistPointsMakeAffine(affine)
+
+	w := msmWindowSize(len(ps))
+	buckets := make([]twistPoint, 1<<w-1)
+	sum, running, windowSum := &twistPoint{}, &twistPoint{}, &twistPoint{}
+	sum.SetInfinity()
+
+	for i := int((256+w-1)/w) - 1; i >= 0; i-- {
+		for j := uint(0); j < w; j++ {
+			sum.Double(sum)
+		}
+
+		for j := range buckets {
+			buckets[j].SetInfinity()
+		}
+		for j := range affine {
+			if d := scalarWindow(&ks[j], uint(i)*w, w); d != 0 {
+				buckets[d-1].AddMixed(&buckets[d-1], &affine[j])
+			}
+		}
+
+		running.SetInfinity()
+		windowSum.SetInfinity()
+		for j := len(buckets) - 1; j >= 0; j-- {
+			running.Add(running, &buckets[j])
+			windowSum.Add(windowSum, running)
+		}
+		sum.Add(sum, windowSum)
+	}
+
+	c.Set(sum)
+}
@@ -1,6 +1,12 @@
 package bn256
 
-func lineFunctionAdd(r, p *twistPoint, q *curvePoint, r2 *gfP2) (a, b, c *gfP2, rOut *twistPoint) {
+// lineCoefficients holds a line function of the Miller loop, which is
+// evaluated at a point q of G₁ by multiplying b by q.x and c by q.y.
+type lineCoefficients struct {
+	a, b, c gfP2
+}
+
+func lineFunctionAdd(r, p *twistPoint, r2 *gfP2) (l *lineCoefficients, rOut *twistPoint) {
 	// See the mixed addition algorithm from "Faster Computation of the
 	// Tate Pairing", http://arxiv.org/pdf/0904.0854v3.pdf
 	B := (&gfP2{}).Mul(&p.x, &r.t)
@@ -36,20 +42,21 @@ func lineFunctionAdd(r, p *twistPoint, q *curvePoint, r2 *gfP2) (a, b, c *gfP2,
 
 	t.Add(&p.y, &rOut.z).Square(t).Sub(t, r2).Sub(t, &rOut.t)
 
+	l = &lineCoefficients{}
+
 	t2.Mul(L1, &p.x)
 	t2.Add(t2, t2)
-	a = (&gfP2{}).Sub(t2, t)
+	l.a.Sub(t2, t)
 
-	c = (&gfP2{}).MulScalar(&rOut.z, &q.y)
-	c.Add(c, c)
+	l.c.Add(&rOut.z, &rOut.z)
 
-	b = (&gfP2{}).Neg(L1)
-	b.MulScalar(b, &q.x).Add(b, b)
+	l.b.Neg(L1)
+	l.b.Add(&l.b, &l.b)
 
 	return
 }
 
-func lineFunctionDouble(r *twistPoint, q *curvePoint) (a, b, c *gfP2, rOut *twistPoint) {
+func lineFunctionDouble(r *twistPoint) (l *lineCoefficients, rOut *twistPoint) {
 	// See the doubling algorithm for a=0 from "Faster Computation of the
 	// Tate Pairing", http://arxiv.org/pdf/0904.0854v3.pdf
 	A := (&gfP2{}).Square(&r.x)
@@ -76,22 +83,28 @@ func lineFunctionDouble(r *twistPoint, q *curvePoint) (a, b, c *gfP2, rOut *twis
 
 	rOut.t.Square(&rOut.z)
 
+	l = &lineCoefficients{}
+
 	t.Mul(E, &r.t).Add(t, t)
-	b = (&gfP2{}).Neg(t)
-	b.MulScalar(b, &q.x)
+	l.b.Neg(t)
 
-	a = (&gfP2{}).Add(&r.x, E)
-	a.Square(a).Sub(a, A).Sub(a, G)
+	l.a.Add(&r.x, E)
+	l.a.Square(&l.a).Sub(&l.a, A).Sub(&l.a, G)
 	t.Add(B, B).Add(t, t)
-	a.Sub(a, t)
+	l.a.Sub(&l.a, t)
 
-	c = (&gfP2{}).Mul(&rOut.z, &r.t)
-	c.Add(c, c).MulScalar(c, &q.y)
+	l.c.Mul(&rOut.z, &r.t)
+	l.c.Add(&l.c, &l.c)
 
 	return
 }
 
-func mulLine(ret *gfP12, a, b, c *gfP2) {
+// mulLine multiplies ret by the line l evaluated at the affine point q.
+func mulLine(ret *gfP12, l *lineCoefficients, q *curvePoint) {
+	a := &l.a
+	b := (&gfP2{}).MulScalar(&l.b, &q.x)
+	c := (&gfP2{}).MulScalar(&l.c, &q.y)
+
 	a2 := &gfP6{}
 	a2.y.Set(a)
 	a2.z.Set(b)
@@ -117,16 +130,35 @@ var sixuPlus2NAF = []int8{0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 1, 0, 0, 0, -1, 0,
 // miller implements the Miller loop for calculating the Optimal Ate pairing.
 // See algorithm 1 from http://cryptojedi.org/papers/dclxvi-20100714.pdf
 func miller(q *twistPoint, p *curvePoint) *gfP12 {
-	ret := (&gfP12{}).SetOne()
+	return multiMiller([]*twistPoint{q}, []*curvePoint{p})
+}
+
+// multiMiller computes the product of the Miller loops of the pairs (qs[i],
+// ps[i]). Pairs where either point is at infinity contribute nothing to the
+// product.
+func multiMiller(qs []*twistPoint, ps []*curvePoint) *gfP12 {
+	lines := make([][]lineCoefficients, len(qs))
+	for i, q := range qs {
+		if !ps[i].IsInfinity() {
+			lines[i] = millerLines(q)
+		}
+	}
+	return millerEval(lines, ps)
+}
+
+// millerLines computes the line functions of the Miller loop for q, in the
+// order in which they are multiplied into the result. It returns nil if q is
+// the point at infinity.
+func millerLines(q *twistPoint) []lineCoefficients {
+	if q.IsInfinity() {
+		return nil
+	}
+	lines := make([]lineCoefficients, 0, len(sixuPlus2NAF)+len(sixuPlus2NAF)/2)
 
 	aAffine := &twistPoint{}
 	aAffine.Set(q)
 	aAffine.MakeAffine()
 
-	bAffine := &curvePoint{}
-	bAffine.Set(p)
-	bAffine.MakeAffine()
-
 	minusA := &twistPoint{}
 	minusA.Neg(aAffine)
 
@@ -136,24 +168,20 @@ func miller(q *twistPoint, p *curvePoint) *gfP12 {
 	r2 := (&gfP2{}).Square(&aAffine.y)
 
 	for i := len(sixuPlus2NAF) - 1; i > 0; i-- {
-		a, b, c, newR := lineFunctionDouble(r, bAffine)
-		if i != len(sixuPlus2NAF)-1 {
-			ret.Square(ret)
-		}
-
-		mulLine(ret, a, b, c)
+		l, newR := lineFunctionDouble(r)
+		lines = append(lines, *l)
 		r = newR
 
 		switch sixuPlus2NAF[i-1] {
 		case 1:
-			a, b, c, newR = lineFunctionAdd(r, aAffine, bAffine, r2)
+			l, newR = lineFunctionAdd(r, aAffine, r2)
 		case -1:
-			a, b, c, newR = lineFunctionAdd(r, minusA, bAffine, r2)
+			l, newR = lineFunctionAdd(r, minusA, r2)
 		default:
 			continue
 		}
 
-		mulLine(ret, a, b, c)
+		lines = append(lines, *l)
 		r = newR
 	}
 
@@ -173,10 +201,7 @@ func miller(q *twistPoint, p *curvePoint) *gfP12 {
 	// A similar argument can be made for the y value.
 
 	q1 := &twistPoint{}
-	q1.x.Conjugate(&aAffine.x).Mul(&q1.x, xiToPMinus1Over3)
-	q1.y.Conjugate(&aAffine.y).Mul(&q1.y, xiToPMinus1Over2)
-	q1.z.SetOne()
-	q1.t.SetOne()
+	q1.Frobenius(aAffine)
 
 	// For Q2 we are applying the p² Frobenius. The two conjugations cancel
 	// out and we are left only with the factors from the isomorphism. In
@@ -191,13 +216,61 @@ func miller(q *twistPoint, p *curvePoint) *gfP12 {
 	minusQ2.t.SetOne()
 
 	r2.Square(&q1.y)
-	a, b, c, newR := lineFunctionAdd(r, q1, bAffine, r2)
-	mulLine(ret, a, b, c)
+	l, newR := lineFunctionAdd(r, q1, r2)
+	lines = append(lines, *l)
 	r = newR
 
 	r2.Square(&minusQ2.y)
-	a, b, c, _ = lineFunctionAdd(r, minusQ2, bAffine, r2)
-	mulLine(ret, a, b, c)
+	l, _ = lineFunctionAdd(r, minusQ2, r2)
+	lines = append(lines, *l)
+
+	return lines
+}
+
+// millerEval evaluates the line functions lines[i] at ps[i] and returns the
+// product of the resulting Miller loops. The loops are run side by side so
+// that the squarings of the accumulator are shared between them. Pairs where
+// lines[i] is nil or ps[i] is at infinity are skipped.
+func millerEval(lines [][]lineCoefficients, ps []*curvePoint) *gfP12 {
+	ret := (&gfP12{}).SetOne()
+
+	active := make([][]lineCoefficients, 0, len(lines))
+	bAffine := make([]*curvePoint, 0, len(ps))
+	for j := range lines {
+		if lines[j] == nil || ps[j].IsInfinity() {
+			continue
+		}
+		active = append(active, lines[j])
+
+		b := &curvePoint{}
+		b.Set(ps[j])
+		b.MakeAffine()
+		bAffine = append(bAffine, b)
+	}
+
+	k := 0
+	for i := len(sixuPlus2NAF) - 1; i > 0; i-- {
+		if i != len(sixuPlus2NAF)-1 {
+			ret.Square(ret)
+		}
+
+		n := 1
+		if sixuPlus2NAF[i-1] != 0 {
+			n = 2
+		}
+		for j := range active {
+			for _, l := range active[j][k : k+n] {
+				mulLine(ret, &l, bAffine[j])
+			}
+		}
+		k += n
+	}
+
+	for j := range active {
+		for _, l := range active[j][k:] {
+			mulLine(ret, &l, bAffine[j])
+		}
+	}
 
 	return ret
 }
@@ -223,9 +296,11 @@ func finalExponentiation(in *gfP12) *gfP12 {
 	fp2 := (&gfP12{}).FrobeniusP2(t1)
 	fp3 := (&gfP12{}).Frobenius(fp2)
 
-	fu := (&gfP12{}).Exp(t1, u)
-	fu2 := (&gfP12{}).Exp(fu, u)
-	fu3 := (&gfP12{}).Exp(fu2, u)
+	// t1 is now in the cyclotomic subgroup, so squarings can use the faster
+	// cyclotomic formula.
+	fu := (&gfP12{}).CyclotomicExp(t1, u)
+	fu2 := (&gfP12{}).CyclotomicExp(fu, u)
+	fu3 := (&gfP12{}).CyclotomicExp(fu2, u)
 
 	y3 := (&gfP12{}).Frobenius(fu)
 	fu2p := (&gfP12{}).Frobenius(fu2)
@@ -244,24 +319,18 @@ func finalExponentiation(in *gfP12) *gfP12 {
 	y6 := (&gfP12{}).Mul(fu3, fu3p)
 	y6.Conjugate(y6)
 
-	t0 := (&gfP12{}).Square(y6)
+	t0 := (&gfP12{}).CyclotomicSquare(y6)
 	t0.Mul(t0, y4).Mul(t0, y5)
 	t1.Mul(y3, y5).Mul(t1, t0)
 	t0.Mul(t0, y2)
-	t1.Square(t1).Mul(t1, t0).Square(t1)
+	t1.CyclotomicSquare(t1).Mul(t1, t0).CyclotomicSquare(t1)
 	t0.Mul(t1, y1)
 	t1.Mul(t1, y0)
-	t0.Square(t0).Mul(t0, t1)
+	t0.CyclotomicSquare(t0).Mul(t0, t1)
 
 	return t0
 }
 
 func optimalAte(a *twistPoint, b *curvePoint) *gfP12 {
-	e := miller(a, b)
-	ret := finalExponentiation(e)
-
-	if a.IsInfinity() || b.IsInfinity() {
-		ret.SetOne()
-	}
-	return ret
+	return finalExponentiation(miller(a, b))
 }
@@ -0,0 +1,281 @@
+package bn256
+
+import (
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// Scalar is an integer modulo Order. Unlike *big.Int, its arithmetic runs in
+// constant time and doesn't allocate, so it is suitable for secret values. The
+// zero value is the integer 0.
+type Scalar struct {
+	v [4]uint64 // value is v·R⁻¹ mod Order, where R = 2^256
+}
+
+// RandomScalar returns a uniformly random Scalar read from r.
+func RandomScalar(r io.Reader) (*Scalar, error) {
+	// Reducing 48 bytes modulo Order gives a bias of less than 2^-128.
+	var buf [48]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, err
+	}
+	return new(Scalar).setWideBytes(&buf), nil
+}
+
+// setWideBytes sets e to the big-endian integer in buf, reduced modulo Order,
+// and then returns e.
+func (e *Scalar) setWideBytes(buf *[48]byte) *Scalar {
+	// buf·R = hi·R³/R + lo·R²/R, where buf = hi·2^256 + lo.
+	hi, lo := [4]uint64{}, [4]uint64{}
+	for w := 0; w < 2; w++ {
+		for b := 0; b < 8; b++ {
+			hi[1-w] = hi[1-w]<<8 | uint64(buf[8*w+b])
+		}
+	}
+	for w := 0; w < 4; w++ {
+		for b := 0; b < 8; b++ {
+			lo[3-w] = lo[3-w]<<8 | uint64(buf[16+8*w+b])
+		}
+	}
+	scalarMul(&hi, &hi, &orderR3)
+	scalarMul(&lo, &lo, &orderR2)
+	scalarAdd(&e.v, &hi, &lo)
+	return e
+}
+
+// Set sets e to a and then returns e.
+func (e *Scalar) Set(a *Scalar) *Scalar {
+	e.v = a.v
+	return e
+}
+
+// SetUint64 sets e to x and then returns e.
+func (e *Scalar) SetUint64(x uint64) *Scalar {
+	e.v = [4]uint64{x}
+	scalarMul(&e.v, &e.v, &orderR2)
+	return e
+}
+
+// SetBigInt sets e to k mod Order and then returns e. Apart from the length of
+// k, which *big.Int doesn't hide, its timing doesn't depend on k.
+func (e *Scalar) SetBigInt(k *big.Int) *Scalar {
+	// Going from the most significant 256 bits of |k| down, v·2^256 + w is
+	// (v·R)·R²/R + w·R²/R in Montgomery form.
+	ws := bigWords(k)
+	e.v = [4]uint64{}
+	for i := len(ws) - 4; i >= 0; i -= 4 {
+		w := (*[4]uint64)(ws[i:])
+		scalarMul(&e.v, &e.v, &orderR2)
+		scalarMul(w, w, &orderR2)
+		scalarAdd(&e.v, &e.v, w)
+	}
+
+	neg := [4]uint64{}
+	scalarSub(&neg, &neg, &e.v)
+	mask := uint64(int64(k.Sign()) >> 63)
+	for i := range e.v {
+		e.v[i] = e.v[i]&^mask | neg[i]&mask
+	}
+	return e
+}
+
+// bigWords returns |k| as little-endian 64-bit words, padded with zeros to a
+// multiple of four words. Its timing depends only on the length of k.
+func bigWords(k *big.Int) []uint64 {
+	bs := k.Bits()
+	out := make([]uint64, (len(bs)*bits.UintSize+255)/256*4)
+	for i, b := range bs {
+		out[i*bits.UintSize/64] |= uint64(b) << (i * bits.UintSize % 64)
+	}
+	return out
+}
+
+// BigInt returns e as an integer between 0 and Order-1.
+func (e *Scalar) BigInt() *big.Int {
+	return new(big.Int).SetBytes(e.Marshal())
+}
+
+// words returns e as little-endian 64-bit words.
+func (e *Scalar) words() [4]uint64 {
+	out := [4]uint64{}
+	scalarMul(&out, &e.v, &[4]uint64{1})
+	return out
+}
+
+// Add sets e to a+b and then returns e.
+func (e *Scalar) Add(a, b *Scalar) *Scalar {
+	scalarAdd(&e.v, &a.v, &b.v)
+	return e
+}
+
+// Sub sets e to a-b and then returns e.
+func (e *Scalar) Sub(a, b *Scalar) *Scalar {
+	scalarSub(&e.v, &a.v, &b.v)
+	return e
+}
+
+// Neg sets e to -a and then returns e.
+func (e *Scalar) Neg(a *Scalar) *Scalar {
+	scalarSub(&e.v, &[4]uint64{}, &a.v)
+	return e
+}
+
+// Mul sets e to a·b and then returns e.
+func (e *Scalar) Mul(a, b *Scalar) *Scalar {
+	scalarMul(&e.v, &a.v, &b.v)
+	return e
+}
+
+// Invert sets e to 1/a and then returns e. The inverse of 0 is 0.
+func (e *Scalar) Invert(a *Scalar) *Scalar {
+	// By Fermat's little theorem, 1/a = a^(Order-2). The exponent is public,
+	// so a fixed-window addition chain over it runs in constant time.
+	table := [16][4]uint64{new(Scalar).SetUint64(1).v, a.v}
+	for i := 2; i < len(table); i++ {
+		scalarMul(&table[i], &table[i-1], &a.v)
+	}
+
+	sum := table[0]
+	for i := 63; i >= 0; i-- {
+		scalarMul(&sum, &sum, &sum)
+		scalarMul(&sum, &sum, &sum)
+		scalarMul(&sum, &sum, &sum)
+		scalarMul(&sum, &sum, &sum)
+		scalarMul(&sum, &sum, &table[orderMinus2[i/16]>>(4*uint(i%16))&15])
+	}
+
+	e.v = sum
+	return e
+}
+
+// Equal returns true iff e and a are equal, in constant time.
+func (e *Scalar) Equal(a *Scalar) bool {
+	v := (e.v[0] ^ a.v[0]) | (e.v[1] ^ a.v[1]) | (e.v[2] ^ a.v[2]) | (e.v[3] ^ a.v[3])
+	return (v|-v)>>63 == 0
+}
+
+// IsZero returns true iff e is 0, in constant time.
+func (e *Scalar) IsZero() bool {
+	return e.Equal(&Scalar{})
+}
+
+// Marshal converts e to a 32-byte big-endian integer between 0 and Order-1.
+func (e *Scalar) Marshal() []byte {
+	w := e.words()
+	out := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		for b := 0; b < 8; b++ {
+			out[8*i+b] = byte(w[3-i] >> (56 - 8*uint(b)))
+		}
+	}
+	return out
+}
+
+// Unmarshal sets e to the result of converting the output of Marshal back into
+// a Scalar and then returns the rest of m. It returns an error if the encoded
+// integer isn't strictly less than Order.
+func (e *Scalar) Unmarshal(m []byte) ([]byte, error) {
+	if len(m) < 32 {
+		return nil, newDecodeError("Scalar", len(m), ErrShortBuffer)
+	}
+
+	w := [4]uint64{}
+	for i := 0; i < 4; i++ {
+		for b := 0; b < 8; b++ {
+			w[3-i] = w[3-i]<<8 | uint64(m[8*i+b])
+		}
+	}
+
+	var borrow uint64
+	for i := 0; i < 4; i++ {
+		_, borrow = bits.Sub64(w[i], order2[i], borrow)
+	}
+	if borrow == 0 {
+		return nil, newDecodeError("Scalar", 0, ErrNonCanonical)
+	}
+
+	scalarMul(&e.v, &w, &orderR2)
+	return m[32:], nil
+}
+
+// scalarReduce sets c to t mod Order, where t = head·2^256 + a < 2·Order, in
+// constant time.
+func scalarReduce(c, a *[4]uint64, head uint64) {
+	b := [4]uint64{}
+	var borrow uint64
+	for i := 0; i < 4; i++ {
+		b[i], borrow = bits.Sub64(a[i], order2[i], borrow)
+	}
+	_, borrow = bits.Sub64(head, 0, borrow)
+
+	// If a-Order is negative, keep a.
+	mask := -borrow
+	for i := 0; i < 4; i++ {
+		c[i] = a[i]&mask | b[i]&^mask
+	}
+}
+
+func scalarAdd(c, a, b *[4]uint64) {
+	t := [4]uint64{}
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		t[i], carry = bits.Add64(a[i], b[i], carry)
+	}
+	scalarReduce(c, &t, carry)
+}
+
+func scalarSub(c, a, b *[4]uint64) {
+	t := [4]uint64{}
+	var borrow uint64
+	for i := 0; i < 4; i++ {
+		t[i], borrow = bits.Sub64(a[i], b[i], borrow)
+	}
+
+	// If a-b is negative, add Order.
+	mask := -borrow
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		c[i], carry = bits.Add64(t[i], order2[i]&mask, carry)
+	}
+}
+
+// scalarMul sets c to a·b·R⁻¹ mod Order with Montgomery multiplication. See
+// "Analyzing and Comparing Montgomery Multiplication Algorithms", Koç et al.,
+// section 5 (CIOS).
+func scalarMul(c, a, b *[4]uint64) {
+	var t [6]uint64
+
+	for i := 0; i < 4; i++ {
+		// t += a·b[i]
+		var carry, cc uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[j], b[i])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, carry, 0)
+			hi += cc
+			t[j], carry = lo, hi
+		}
+		t[4], t[5] = bits.Add64(t[4], carry, 0)
+
+		// t = (t + m·Order)/2^64, where m is chosen so that the division is
+		// exact.
+		m := t[0] * orderNp
+		hi, lo := bits.Mul64(m, order2[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		carry = hi + cc
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(m, order2[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, carry, 0)
+			hi += cc
+			t[j-1], carry = lo, hi
+		}
+		t[3], cc = bits.Add64(t[4], carry, 0)
+		t[4] = t[5] + cc
+	}
+
+	scalarReduce(c, &[4]uint64{t[0], t[1], t[2], t[3]}, t[4])
+}
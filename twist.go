@@ -31,8 +31,10 @@ var twistGen = &twistPoint{
 }
 
 func (c *twistPoint) String() string {
-	c.MakeAffine()
-	x, y := gfP2Decode(&c.x), gfP2Decode(&c.y)
+	a := &twistPoint{}
+	a.Set(c)
+	a.MakeAffine()
+	x, y := gfP2Decode(&a.x), gfP2Decode(&a.y)
 	return "(" + x.String() + ", " + y.String() + ")"
 }
 
@@ -43,20 +45,68 @@ func (c *twistPoint) Set(a *twistPoint) {
 	c.t.Set(&a.t)
 }
 
-// IsOnCurve returns true iff c is on the curve.
+// Cmov sets c to a if cond is 1 and leaves it unchanged if cond is 0, in
+// constant time.
+func (c *twistPoint) Cmov(a *twistPoint, cond int) {
+	c.x.Cmov(&a.x, cond)
+	c.y.Cmov(&a.y, cond)
+	c.z.Cmov(&a.z, cond)
+	c.t.Cmov(&a.t, cond)
+}
+
+// IsOnCurve returns true iff c is on the curve. It doesn't modify c.
 func (c *twistPoint) IsOnCurve() bool {
-	c.MakeAffine()
-	if c.IsInfinity() {
+	a := &twistPoint{}
+	a.Set(c)
+	a.MakeAffine()
+	if a.IsInfinity() {
 		return true
 	}
 
 	y2, x3 := &gfP2{}, &gfP2{}
-	y2.Square(&c.y)
-	x3.Square(&c.x).Mul(x3, &c.x).Add(x3, twistB)
+	y2.Square(&a.y)
+	x3.Square(&a.x).Mul(x3, &a.x).Add(x3, twistB)
 
 	return *y2 == *x3
 }
 
+// IsInSubgroup returns true iff c is in G₂, the subgroup of order Order.
+func (c *twistPoint) IsInSubgroup() bool {
+	// On G₂, ψ acts as multiplication by p ≡ 6u² (mod Order). Conversely, ψ
+	// satisfies ψ²-tψ+p = 0 where t = 6u²+1 is the trace, so if ψ(c) = [6u²]c
+	// then [36u⁴-t·6u²+p]c = [p-6u²]c = [Order]c = 0.
+	a, b := &twistPoint{}, &twistPoint{}
+	a.Frobenius(c)
+	a.MakeAffine()
+	b.MulGeneric(c, sixuSquared)
+	b.MakeAffine()
+
+	return a.x == b.x && a.y == b.y && a.z == b.z
+}
+
+// ClearCofactor sets c to a point of G₂ computed from a, which can be any point
+// on the twist, as [u]a + ψ([3u]a) + ψ²([u]a) + ψ³(a). See "Faster Hashing to
+// G2", Fuentes-Castañeda, Knapp and Rodríguez-Henríquez, section 6.1.
+func (c *twistPoint) ClearCofactor(a *twistPoint) {
+	ua, u3a, t := &twistPoint{}, &twistPoint{}, &twistPoint{}
+	ua.MulGeneric(a, u)
+	u3a.Double(ua)
+	u3a.Add(u3a, ua)
+
+	sum := &twistPoint{}
+	sum.Frobenius(u3a)
+	sum.Add(sum, ua)
+	t.Frobenius(ua)
+	t.Frobenius(t)
+	sum.Add(sum, t)
+	t.Frobenius(a)
+	t.Frobenius(t)
+	t.Frobenius(t)
+	sum.Add(sum, t)
+
+	c.Set(sum)
+}
+
 func (c *twistPoint) SetInfinity() {
 	c.x.SetZero()
 	c.y.SetOne()
@@ -68,6 +118,30 @@ func (c *twistPoint) IsInfinity() bool {
 	return c.z.IsZero()
 }
 
+// Equal returns true iff c and a are the same point. It compares their Jacobian
+// coordinates without converting either to affine form.
+func (c *twistPoint) Equal(a *twistPoint) bool {
+	if c.IsInfinity() || a.IsInfinity() {
+		return c.IsInfinity() && a.IsInfinity()
+	}
+
+	// See the same function for curvePoint.
+	z12 := (&gfP2{}).Square(&c.z)
+	z22 := (&gfP2{}).Square(&a.z)
+
+	u1 := (&gfP2{}).Mul(&c.x, z22)
+	u2 := (&gfP2{}).Mul(&a.x, z12)
+	if *u1 != *u2 {
+		return false
+	}
+
+	z12.Mul(z12, &c.z)
+	z22.Mul(z22, &a.z)
+	u1.Mul(&c.y, z22)
+	u2.Mul(&a.y, z12)
+	return *u1 == *u2
+}
+
 func (c *twistPoint) Add(a, b *twistPoint) {
 	// For additional comments, see the same function in curve.go.
 
@@ -127,6 +201,63 @@ func (c *twistPoint) Add(a, b *twistPoint) {
 	c.z.Mul(t4, h)
 }
 
+// AddMixed sets c to a+b, where b must be in affine form (z=1) unless it is
+// the point at infinity.
+func (c *twistPoint) AddMixed(a, b *twistPoint) {
+	// For additional comments, see the same function in curve.go.
+
+	if a.IsInfinity() {
+		c.Set(b)
+		return
+	}
+	if b.IsInfinity() {
+		c.Set(a)
+		return
+	}
+
+	// See http://hyperelliptic.org/EFD/g1p/auto-code/shortw/jacobian-0/addition/madd-2007-bl.op3
+	z12 := (&gfP2{}).Square(&a.z)
+	u2 := (&gfP2{}).Mul(&b.x, z12)
+	s2 := (&gfP2{}).Mul(&b.y, &a.z)
+	s2.Mul(s2, z12)
+
+	h := (&gfP2{}).Sub(u2, &a.x)
+	r := (&gfP2{}).Sub(s2, &a.y)
+	if h.IsZero() {
+		if r.IsZero() {
+			c.Double(a)
+		} else {
+			c.SetInfinity()
+		}
+		return
+	}
+	r.Add(r, r)
+
+	hh := (&gfP2{}).Square(h)
+	i := (&gfP2{}).Add(hh, hh)
+	i.Add(i, i)
+	j := (&gfP2{}).Mul(h, i)
+	v := (&gfP2{}).Mul(&a.x, i)
+
+	t := (&gfP2{}).Add(v, v)
+	x3 := (&gfP2{}).Square(r)
+	x3.Sub(x3, j).Sub(x3, t)
+
+	t.Sub(v, x3)
+	y3 := (&gfP2{}).Mul(r, t)
+	t.Mul(&a.y, j)
+	t.Add(t, t)
+	y3.Sub(y3, t)
+
+	z3 := (&gfP2{}).Add(&a.z, h)
+	z3.Square(z3).Sub(z3, z12).Sub(z3, hh)
+
+	c.x.Set(x3)
+	c.y.Set(y3)
+	c.z.Set(z3)
+	c.t.Square(z3)
+}
+
 func (c *twistPoint) Double(a *twistPoint) {
 	// See http://hyperelliptic.org/EFD/g1p/auto-code/shortw/jacobian-0/doubling/dbl-2009-l.op3
 	A := (&gfP2{}).Square(&a.x)
@@ -156,7 +287,58 @@ func (c *twistPoint) Double(a *twistPoint) {
 	c.y.Sub(t2, t)
 }
 
+// Mul sets c to a*scalar. a must be in G₂, because the scalar is reduced
+// modulo Order and split into four parts with the GLS method, using ψ.
 func (c *twistPoint) Mul(a *twistPoint, scalar *big.Int) {
+	ks := frobeniusLattice.decompose(scalar)
+
+	// The table holds combinations of a, ψ(a), ψ²(a) and ψ³(a) where bit i of
+	// the index selects ψⁱ(a). Negative parts are handled by negating the
+	// corresponding point.
+	table := [16]twistPoint{}
+	table[0].SetInfinity()
+	table[1].Set(a)
+	for i := 1; i < 4; i++ {
+		table[1<<uint(i)].Frobenius(&table[1<<uint(i-1)])
+	}
+	for i, k := range ks {
+		if k.Sign() < 0 {
+			table[1<<uint(i)].Neg(&table[1<<uint(i)])
+			ks[i] = new(big.Int).Neg(k)
+		}
+	}
+	for i := 3; i < 16; i++ {
+		if low := i & -i; low != i {
+			table[i].Add(&table[i-low], &table[low])
+		}
+	}
+
+	n := 0
+	for _, k := range ks {
+		if m := k.BitLen(); m > n {
+			n = m
+		}
+	}
+
+	sum, t := &twistPoint{}, &twistPoint{}
+	sum.SetInfinity()
+
+	for i := n - 1; i >= 0; i-- {
+		t.Double(sum)
+		idx := ks[0].Bit(i) | ks[1].Bit(i)<<1 | ks[2].Bit(i)<<2 | ks[3].Bit(i)<<3
+		if idx != 0 {
+			sum.Add(t, &table[idx])
+		} else {
+			sum.Set(t)
+		}
+	}
+
+	c.Set(sum)
+}
+
+// MulGeneric sets c to a*scalar using double-and-add. Unlike Mul, it is
+// correct for any point on the twist.
+func (c *twistPoint) MulGeneric(a *twistPoint, scalar *big.Int) {
 	sum, t := &twistPoint{}, &twistPoint{}
 
 	for i := scalar.BitLen(); i >= 0; i-- {
@@ -191,9 +373,19 @@ func (c *twistPoint) MakeAffine() {
 	c.t.SetOne()
 }
 
+// Frobenius sets c to ψ(a), where ψ is the endomorphism that maps a to the
+// full GF(p¹²) curve, applies the p-power Frobenius there and maps the result
+// back onto the twist. See miller for the details.
+func (c *twistPoint) Frobenius(a *twistPoint) {
+	c.x.Conjugate(&a.x).Mul(&c.x, xiToPMinus1Over3)
+	c.y.Conjugate(&a.y).Mul(&c.y, xiToPMinus1Over2)
+	c.z.Conjugate(&a.z)
+	c.t.Conjugate(&a.t)
+}
+
 func (c *twistPoint) Neg(a *twistPoint) {
 	c.x.Set(&a.x)
 	c.y.Neg(&a.y)
 	c.z.Set(&a.z)
-	c.t.SetZero()
+	c.t.Set(&a.t)
 }